@@ -15,8 +15,9 @@ import (
 )
 
 var (
-	numClients int
-	numWorkers int
+	numClients      int
+	numWorkers      int
+	solveChallenges bool
 )
 
 var rootCmd = &cobra.Command{
@@ -49,12 +50,17 @@ var rootCmd = &cobra.Command{
 			logger.Fatal("Number of workers must be greater than 0")
 		}
 
+		managerOpts := []client.ManagerOption{}
+		if solveChallenges {
+			managerOpts = append(managerOpts, client.WithChallengeSolving())
+		}
+
 		clientManagers := []*client.Manager{}
 
 		for i := 0; i < numClients; i++ {
 			clientId := strconv.Itoa(i + 1)
 
-			workerManager := client.NewManager(url, clientId)
+			workerManager := client.NewManager(url, clientId, managerOpts...)
 			workerManager.SpawnClients(numWorkers)
 
 			clientManagers = append(clientManagers, workerManager)
@@ -76,6 +82,7 @@ func main() {
 
 	rootCmd.Flags().IntVar(&numClients, "clients", 1, "Number of clients to spawn")
 	rootCmd.Flags().IntVar(&numWorkers, "workers", 1, "Number of workers per client")
+	rootCmd.Flags().BoolVar(&solveChallenges, "solve-challenges", false, "Solve the proof-of-work challenges of the limited requests")
 
 	if err := rootCmd.Execute(); err != nil {
 		zap.L().Fatal("Unable to run", zap.Error(err))
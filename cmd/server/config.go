@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/config"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+var configPath string
+
+var validateConfigCmd = &cobra.Command{
+	Use:   "validate-config [file]",
+	Short: "Validate the configuration file",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := configPath
+		if len(args) == 1 {
+			path = args[0]
+		}
+
+		if path == "" {
+			return errors.New("requires a configuration file")
+		}
+
+		cmd.SilenceUsage = true
+
+		_, err := config.Load(path, config.FromEnv())
+		validationErr := config.ValidationError{}
+		switch {
+		case errors.As(err, &validationErr):
+			for _, fieldErr := range validationErr {
+				fmt.Fprintf(cmd.ErrOrStderr(), "%s:%d: %s: %s\n", path, fieldErr.Line, fieldErr.Field, fieldErr.Message)
+			}
+			os.Exit(1)
+		case err != nil:
+			fmt.Fprintf(cmd.ErrOrStderr(), "%s: %s\n", path, err.Error())
+			os.Exit(1)
+		}
+
+		fmt.Fprintf(cmd.OutOrStdout(), "%s: configuration is valid\n", path)
+		return nil
+	},
+}
+
+// flagOverrides overrides the configuration with the flags that were explicitly set.
+func flagOverrides(cmd *cobra.Command) config.Override {
+	return func(cfg *config.Config) (err error) {
+		flags := cmd.Flags()
+
+		if flags.Changed("address") {
+			cfg.Server.Address, err = flags.GetString("address")
+		}
+
+		if err == nil && flags.Changed("admin-address") {
+			cfg.Admin.Address, err = flags.GetString("admin-address")
+		}
+
+		if err == nil && flags.Changed("algorithm") {
+			cfg.Limiter.Algorithm, err = flags.GetString("algorithm")
+		}
+
+		if err == nil && flags.Changed("limit") {
+			cfg.Limiter.Limit, err = flags.GetInt("limit")
+		}
+
+		if err == nil && flags.Changed("duration") {
+			cfg.Limiter.Duration, err = flags.GetDuration("duration")
+		}
+
+		if err == nil && flags.Changed("log-level") {
+			cfg.Logging.Level, err = flags.GetString("log-level")
+		}
+
+		return err
+	}
+}
+
+// setupLogger replaces the global logger with the one configured.
+func setupLogger(cfg config.Logging) {
+	zapConfig := zap.NewProductionConfig()
+	if cfg.Format == "console" {
+		zapConfig = zap.NewDevelopmentConfig()
+	}
+
+	level, err := zapcore.ParseLevel(cfg.Level)
+	if err == nil {
+		zapConfig.Level = zap.NewAtomicLevelAt(level)
+	}
+
+	logger, err := zapConfig.Build()
+	if err != nil {
+		zap.L().Fatal("Failed to set up the logger", zap.Error(err))
+	}
+
+	zap.ReplaceGlobals(logger)
+}
@@ -1,13 +1,33 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
+	"time"
 
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/access"
 	http2 "github.com/xBlaz3kx/rate-limiter-example/internal/server/api/http"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/challenge"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/config"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/fairqueue"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/heavyhitters"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/keys"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/metrics"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/penalty"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/quota"
 	ratelimiter "github.com/xBlaz3kx/rate-limiter-example/internal/server/rate-limiter"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/shedding"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/tarpit"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/tracing"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 	"go.uber.org/zap"
 )
 
@@ -17,28 +37,305 @@ var rootCmd = &cobra.Command{
 		quit := make(chan os.Signal, 1)
 		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		cfg, err := config.Load(configPath, config.FromEnv(), flagOverrides(cmd))
+		if err != nil {
+			zap.L().Fatal("Invalid configuration", zap.Error(err))
+		}
+
+		setupLogger(cfg.Logging)
 		logger := zap.L()
 		logger.Info("Starting the server")
 
-		// Set up the rate limiter
-		slidingWindowLimiter := ratelimiter.NewSlidingWindowRateLimiter()
+		// Set up the tracing
+		shutdownTracing, err := tracing.Setup(ctx, tracing.Config{
+			Exporter:    cfg.Tracing.Exporter,
+			Endpoint:    cfg.Tracing.Endpoint,
+			Insecure:    cfg.Tracing.Insecure,
+			SampleRatio: cfg.Tracing.SampleRatio,
+			ServiceName: cfg.Tracing.ServiceName,
+		})
+		if err != nil {
+			logger.Fatal("Failed to set up tracing", zap.Error(err))
+		}
+
+		// Set up the metrics, driven by the limiter events and the handler decisions
+		limiterMetrics := metrics.New()
+
+		// Track the heaviest clients by all their requests
+		heavyHitters := heavyhitters.NewTracker()
+
+		// Set up the client ID extraction
+		keyExtractor, routeKeyExtractors, err := keyExtractors(cfg)
+		if err != nil {
+			logger.Fatal("Invalid key configuration", zap.Error(err))
+		}
+
+		// Set up the rate limiters
+		policies := &policyHolder{observers: []ratelimiter.Observer{limiterMetrics}}
+		var currentPolicy http2.Policy
+		policies.update(cfg, func(p *policy) {
+			currentPolicy = handlerPolicy(cfg, p, keyExtractor, routeKeyExtractors)
+		})
+		limiterMetrics.TrackClients(policies.trackedClients)
+		limiterMetrics.TrackShadowStats(policies.shadowStats)
+
+		// Periodically free up the state of inactive clients. The interval follows the window duration on reload.
+		evictTicker := time.NewTicker(cfg.Limiter.Duration)
+		go func() {
+			defer evictTicker.Stop()
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-evictTicker.C:
+					policies.evictExpired()
+				}
+			}
+		}()
+
+		// Set up the access lists, which can be edited at runtime
+		allowList, _ := access.NewList(cfg.Access.Allow...)
+		denyList, _ := access.NewList(cfg.Access.Deny...)
+
+		handlerOpts := []http2.HandlerOption{
+			http2.WithPolicy(currentPolicy),
+			http2.WithAccessLists(allowList, denyList),
+			http2.WithMetrics(limiterMetrics),
+			http2.WithHeavyHitterTracking(heavyHitters),
+		}
+		adminOpts := []http2.AdminOption{
+			http2.WithAccessListManagement(allowList, denyList),
+			http2.WithShadowStats(policies.shadowStats),
+			http2.WithClientManagement(policies.inspector),
+			http2.WithHeavyHitters(heavyHitters),
+		}
+
+		// Set up the penalty box for repeat offenders
+		var penaltyBox *penalty.Box
+		if cfg.Penalty.Enabled {
+			penaltyBox = penalty.NewBox(
+				penalty.WithThreshold(cfg.Penalty.Threshold),
+				penalty.WithWindow(cfg.Limiter.Duration, cfg.Penalty.Windows),
+				penalty.WithBanDuration(cfg.Penalty.BaseDuration, cfg.Penalty.MaxDuration),
+				penalty.WithObserver(limiterMetrics),
+			)
+			go penaltyBox.Run(ctx)
+
+			handlerOpts = append(handlerOpts, http2.WithPenaltyBox(penaltyBox))
+			adminOpts = append(adminOpts, http2.WithBanManagement(penaltyBox))
+		}
+
+		// Slow down the abusive clients instead of rejecting them instantly
+		if cfg.Tarpit.Enabled {
+			tarpitted := tarpit.New(
+				tarpit.WithDelay(cfg.Tarpit.BaseDelay, cfg.Tarpit.MaxDelay),
+				tarpit.WithMaxConnections(cfg.Tarpit.MaxConnections),
+				tarpit.WithForgetAfter(cfg.Tarpit.ForgetAfter),
+			)
+			go tarpitted.Run(ctx, cfg.Tarpit.ForgetAfter)
+
+			limiterMetrics.TrackTarpit(tarpitted.Held)
+			handlerOpts = append(handlerOpts, http2.WithTarpit(tarpitted))
+		}
+
+		// Let the limited clients through with a solved proof-of-work challenge
+		if cfg.Challenge.Enabled {
+			secret := []byte(cfg.Challenge.Secret)
+			if len(secret) == 0 {
+				secret = make([]byte, 32)
+				_, _ = rand.Read(secret)
+				logger.Info("Challenges are signed with a random secret and don't survive restarts")
+			}
+
+			issuer := challenge.NewIssuer(secret,
+				challenge.WithDifficulty(cfg.Challenge.Difficulty, cfg.Challenge.MaxDifficulty),
+				challenge.WithTargetRate(cfg.Challenge.TargetRate),
+				challenge.WithTTL(cfg.Challenge.TTL),
+			)
+			handlerOpts = append(handlerOpts, http2.WithChallenges(issuer))
+		}
+
+		// Set up the daily and monthly quotas, persisted across restarts
+		var quotas *quota.Tracker
+		if cfg.Quota.Enabled {
+			location, _ := time.LoadLocation(cfg.Quota.Timezone)
+			quotas, err = quota.NewTracker(
+				quota.NewFileStore(cfg.Quota.Path),
+				quota.WithLocation(location),
+				quota.WithMaxClients(cfg.Quota.MaxClients),
+			)
+			if err != nil {
+				logger.Fatal("Failed to load the quota usage", zap.Error(err))
+			}
+
+			quotas.SetPlans(quotaPlans(cfg.Quota))
+			go quotas.Run(ctx, cfg.Quota.FlushInterval)
+
+			handlerOpts = append(handlerOpts, http2.WithQuotas(quotas))
+			adminOpts = append(adminOpts, http2.WithQuotaUsage(quotas))
+		}
+
+		// Limit the requests each client can have in flight at once
+		if cfg.Limiter.Concurrency > 0 {
+			handlerOpts = append(handlerOpts, http2.WithConcurrencyLimit(ratelimiter.NewConcurrencyLimiter(cfg.Limiter.Concurrency)))
+		}
+
+		// Serve the clients round-robin when the capacity is saturated
+		var queue *fairqueue.Queue
+		if cfg.Queue.Enabled {
+			queue = fairqueue.NewQueue(
+				fairqueue.WithCapacity(cfg.Queue.Capacity),
+				fairqueue.WithMaxLength(cfg.Queue.MaxLength),
+				fairqueue.WithMaxWait(cfg.Queue.MaxWait),
+				fairqueue.WithQuantum(cfg.Queue.Quantum),
+			)
+			limiterMetrics.TrackQueue(queue.Waiting)
+			handlerOpts = append(handlerOpts, http2.WithFairQueue(queue))
+		}
+
+		// Shed the requests over the adaptive server-wide concurrency limit
+		if cfg.Shedding.Enabled {
+			shedder := newShedder(cfg.Shedding, queue)
+			limiterMetrics.TrackLoadShedding(shedder.Limit, shedder.InFlight)
+			handlerOpts = append(handlerOpts, http2.WithLoadShedding(shedder))
+		}
 
 		// Set up the handler
-		ginHandler := http2.NewHandler(slidingWindowLimiter)
+		ginHandler := http2.NewHandler(currentPolicy.Limiter, handlerOpts...)
+
+		// Reload the limiter policies, the access lists and the key extraction. Other settings (e.g. new routes) require a restart.
+		reloadMu := sync.Mutex{}
+		reload := func() error {
+			reloadMu.Lock()
+			defer reloadMu.Unlock()
+
+			cfg, err := config.Load(configPath, config.FromEnv(), flagOverrides(cmd))
+			if err != nil {
+				logger.Error("Failed to reload the configuration", zap.Error(err))
+				return errors.Wrap(err, "failed to reload the configuration")
+			}
+
+			keyExtractor, routeKeyExtractors, err := keyExtractors(cfg)
+			if err != nil {
+				logger.Error("Failed to reload the configuration", zap.Error(err))
+				return errors.Wrap(err, "failed to reload the configuration")
+			}
+
+			// Validated with the configuration
+			_ = allowList.Replace(cfg.Access.Allow...)
+			_ = denyList.Replace(cfg.Access.Deny...)
+
+			// The limiters, the rules, the expressions, the priorities and the key extraction are swapped at once
+			policies.update(cfg, func(p *policy) {
+				ginHandler.SetPolicy(handlerPolicy(cfg, p, keyExtractor, routeKeyExtractors))
+			})
+
+			// The eviction and the offenses follow the duration of the windows
+			evictTicker.Reset(cfg.Limiter.Duration)
+			if penaltyBox != nil {
+				penaltyBox.SetWindow(cfg.Limiter.Duration, cfg.Penalty.Windows)
+			}
+
+			if quotas != nil {
+				quotas.SetPlans(quotaPlans(cfg.Quota))
+			}
+
+			logger.Info("Configuration reloaded")
+			return nil
+		}
+		adminOpts = append(adminOpts, http2.WithReload(reload))
+		adminHandler := http2.NewAdminHandler(adminOpts...)
+
+		// Reload on SIGHUP and on configuration file changes
+		hangup := make(chan os.Signal, 1)
+		signal.Notify(hangup, syscall.SIGHUP)
+		go func() {
+			for range hangup {
+				_ = reload()
+			}
+		}()
+
+		if configPath != "" {
+			err = config.Watch(ctx, configPath, func() { _ = reload() })
+			if err != nil {
+				logger.Warn("Unable to watch the configuration file", zap.Error(err))
+			}
+		}
+
 		// Create a new HTTP server
-		server := http2.NewServer(":80", logger)
+		server, err := http2.NewServer(cfg.Server.Address, cfg.Server.TrustedProxies, logger)
+		if err != nil {
+			logger.Fatal("Failed to create the server", zap.Error(err))
+		}
+
+		server.Router.Use(otelgin.Middleware(cfg.Tracing.ServiceName), limiterMetrics.Middleware())
 		server.Router.GET("", ginHandler.HandleRequest)
+		if quotas != nil {
+			server.Router.GET("/usage", ginHandler.Usage)
+		}
+
+		for i, route := range cfg.Routes {
+			if route.Path == "/" {
+				continue
+			}
 
-		// Start the server
+			// Validated with the configuration, except for the patterns conflicting in the router
+			if err := server.Handle(http.MethodGet, route.Path, ginHandler.HandleRequest); err != nil {
+				logger.Fatal("Invalid configuration", zap.Error(errors.Wrapf(err, "routes.%d.path", i)))
+			}
+		}
+
+		// The rules match on the method and the path, so evaluate the requests to any path
+		if len(cfg.Rules) > 0 {
+			server.Router.NoRoute(ginHandler.HandleRequest)
+		}
+
+		// Create a separate server for the operational endpoints
+		adminServer := http2.NewAdminServer(cfg.Admin.Address, logger, cfg.Admin.Profiling)
+		adminServer.Router.GET("/metrics", gin.WrapH(limiterMetrics.Handler()))
+		if cfg.Admin.Token != "" {
+			adminHandler.RegisterRoutes(adminServer.Router.Group("/admin", http2.AdminAuth(cfg.Admin.Token)))
+		} else {
+			logger.Warn("Admin API is disabled, as the admin token is not set")
+		}
+
+		// Start the servers
+		adminServer.Start()
 		server.Start()
 
-		// Wait for interrupt signal to gracefully shutdown the server
+		// Wait for interrupt signal to gracefully shutdown the servers
 		<-quit
 		logger.Info("Shutting down server")
 
-		err := server.Shutdown()
+		// Stop the background tasks and release the connections held in the tarpit, so the requests can complete
+		cancel()
+
+		// The usage and the spans are flushed even if the servers don't shut down in time
+		err = server.Shutdown()
+		if err != nil {
+			logger.Error("Failed to shutdown server", zap.Error(err))
+		}
+
+		err = adminServer.Shutdown()
 		if err != nil {
-			logger.Fatal("Failed to shutdown server", zap.Error(err))
+			logger.Error("Failed to shutdown admin server", zap.Error(err))
+		}
+
+		// Persist the quota usage of the last requests
+		if quotas != nil {
+			if err := quotas.Flush(); err != nil {
+				logger.Error("Failed to persist the quota usage", zap.Error(err))
+			}
+		}
+
+		// Flush the remaining spans
+		err = shutdownTracing(context.Background())
+		if err != nil {
+			logger.Error("Failed to shutdown tracing", zap.Error(err))
 		}
 	},
 }
@@ -46,6 +343,15 @@ var rootCmd = &cobra.Command{
 func main() {
 	cobra.OnInitialize(setupGlobalLogger)
 
+	rootCmd.PersistentFlags().StringVarP(&configPath, "config", "c", "", "Path to the configuration file")
+	rootCmd.Flags().String("address", "", "Address to listen on")
+	rootCmd.Flags().String("admin-address", "", "Address of the operational endpoints")
+	rootCmd.Flags().String("algorithm", "", "Rate limiting algorithm")
+	rootCmd.Flags().Int("limit", 0, "Maximum number of requests per window")
+	rootCmd.Flags().Duration("duration", 0, "Duration of the window")
+	rootCmd.Flags().String("log-level", "", "Minimum log level")
+	rootCmd.AddCommand(validateConfigCmd)
+
 	if err := rootCmd.Execute(); err != nil {
 		zap.L().Fatal("Unable to run", zap.Error(err))
 	}
@@ -55,3 +361,87 @@ func setupGlobalLogger() {
 	logger, _ := zap.NewProduction()
 	zap.ReplaceGlobals(logger)
 }
+
+// quotaPlans creates the default plan and the plans of the tiers from the configuration.
+func quotaPlans(cfg config.Quota) (quota.Plan, map[string]quota.Plan) {
+	plans := map[string]quota.Plan{}
+	for _, plan := range cfg.Plans {
+		plans[plan.Tier] = quota.Plan{Daily: plan.Daily, Monthly: plan.Monthly}
+	}
+
+	return quota.Plan{Daily: cfg.Daily, Monthly: cfg.Monthly}, plans
+}
+
+// newShedder creates the adaptive limiter with the saturation signals from the configuration.
+// The server is also considered saturated when the fair queue, if enabled, is more than half full.
+func newShedder(cfg config.Shedding, queue *fairqueue.Queue) *shedding.Limiter {
+	opts := []shedding.Options{
+		shedding.WithLimits(cfg.MinLimit, cfg.MaxLimit),
+		shedding.WithInitialLimit(cfg.InitialLimit),
+		shedding.WithTargetLatency(cfg.TargetLatency),
+		shedding.WithBackoff(cfg.Backoff),
+		shedding.WithReserved(cfg.Reserved.Critical, cfg.Reserved.High, cfg.Reserved.Normal),
+	}
+
+	if cfg.MaxGoroutines > 0 {
+		opts = append(opts, shedding.WithSignal(shedding.Goroutines(cfg.MaxGoroutines)))
+	}
+
+	if cfg.MaxSchedulingLatency > 0 {
+		opts = append(opts, shedding.WithSignal(shedding.SchedulingLatency(cfg.MaxSchedulingLatency, time.Second)))
+	}
+
+	if queue != nil {
+		opts = append(opts, shedding.WithSignal(shedding.QueueDepth(queue.Waiting, queue.MaxLength()/2)))
+	}
+
+	return shedding.NewLimiter(opts...)
+}
+
+// handlerPolicy creates the policy of the handler with the limiters of the policy.
+func handlerPolicy(cfg config.Config, p *policy, keyExtractor keys.Extractor, routeKeyExtractors map[string]keys.Extractor) http2.Policy {
+	return http2.Policy{
+		Limiter:            p.limiter,
+		Rules:              p.engine,
+		Expressions:        p.expressions,
+		Classes:            priorityClasses(cfg.Priority),
+		KeyExtractor:       keyExtractor,
+		RouteKeyExtractors: routeKeyExtractors,
+	}
+}
+
+// priorityClasses creates the priority classes validated with the configuration.
+func priorityClasses(cfg config.Priority) *shedding.Classes {
+	defaultPriority, _ := shedding.ParsePriority(cfg.Default)
+	trusted, _ := access.NewList(cfg.Trusted...)
+	classes := &shedding.Classes{
+		Default: defaultPriority,
+		Header:  cfg.Header,
+		Trusted: trusted,
+		Tiers:   map[string]shedding.Priority{},
+	}
+
+	for _, tierPriority := range cfg.Tiers {
+		classes.Tiers[tierPriority.Tier], _ = shedding.ParsePriority(tierPriority.Priority)
+	}
+
+	return classes
+}
+
+// keyExtractors creates the default and the per route client ID extraction from the configuration.
+func keyExtractors(cfg config.Config) (keys.Extractor, map[string]keys.Extractor, error) {
+	extractor, err := keys.FromConfig(cfg.Key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	routes := map[string]keys.Extractor{}
+	for _, route := range cfg.Routes {
+		routes[route.Path], err = keys.FromConfig(route.Key)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "invalid key of the route %s", route.Path)
+		}
+	}
+
+	return extractor, routes, nil
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"fmt"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/access"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/config"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/expressions"
+	ratelimiter "github.com/xBlaz3kx/rate-limiter-example/internal/server/rate-limiter"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/rules"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/schedule"
+)
+
+// policy is the set of limiters built from the configuration.
+type policy struct {
+	// identity of the enforcing and candidate limiters. The limiters are only reused if the identity didn't change.
+	identity string
+
+	enforcing ratelimiter.Limiter
+	candidate ratelimiter.Limiter
+
+	// shadowLimiters are the limiters running in shadow mode
+	shadowLimiters []*ratelimiter.ShadowLimiter
+
+	// limiter is the limiter used by the handler
+	limiter ratelimiter.Limiter
+
+	// ruleLimiters are the enforcing limiters of the rules by the rule name
+	ruleLimiters map[string]ratelimiter.Limiter
+
+	// engine evaluates the rules with their limiters
+	engine *rules.Engine
+
+	// expressions of the requests not matching any rule
+	expressions *expressions.Expressions
+}
+
+// limiters returns all the enforcing and candidate limiters of the policy.
+func (p *policy) limiters() []ratelimiter.Limiter {
+	limiters := []ratelimiter.Limiter{p.enforcing}
+	if p.candidate != nil {
+		limiters = append(limiters, p.candidate)
+	}
+
+	for _, limiter := range p.ruleLimiters {
+		limiters = append(limiters, limiter)
+	}
+
+	return limiters
+}
+
+// limiterIdentity identifies the limiters by the algorithm and its parameters, which can't be changed without losing the state.
+func limiterIdentity(cfg config.Limiter) string {
+	if cfg.Algorithm == "count-min" {
+		width, depth := ratelimiter.SketchSize(cfg.Sketch.Epsilon, cfg.Sketch.Delta)
+		return fmt.Sprintf("%s/%dx%d", cfg.Algorithm, width, depth)
+	}
+
+	return cfg.Algorithm
+}
+
+// newLimiterConfig creates the configuration of a limiter with the alignment of the windows.
+func newLimiterConfig(cfg config.Limiter, limit int, duration time.Duration) ratelimiter.Config {
+	return ratelimiter.Config{Limit: limit, Duration: duration, Aligned: cfg.Alignment == "clock"}
+}
+
+// newLimiter creates a limiter with the configured algorithm.
+func newLimiter(cfg config.Limiter, limiterConfig ratelimiter.Config, observers []ratelimiter.Observer) ratelimiter.Limiter {
+	if cfg.Algorithm == "count-min" {
+		width, depth := ratelimiter.SketchSize(cfg.Sketch.Epsilon, cfg.Sketch.Delta)
+		return ratelimiter.NewCountMinRateLimiter(limiterConfig, width, depth, observers...)
+	}
+
+	opts := []ratelimiter.Options{}
+	for _, observer := range observers {
+		opts = append(opts, ratelimiter.WithObserver(observer))
+	}
+
+	return ratelimiter.NewSlidingWindowRateLimiterFromConfig(limiterConfig, opts...)
+}
+
+// reconfigureLimiter returns a limiter with the limits and the schedule. The previous limiter, if any, is replaced by a
+// limiter sharing the state of its clients, so the previous limiter keeps its limits until the new policy is activated.
+func reconfigureLimiter(cfg config.Limiter, previous ratelimiter.Limiter, limiterConfig ratelimiter.Config, s ratelimiter.Schedule, observers []ratelimiter.Observer) ratelimiter.Limiter {
+	switch limiter := previous.(type) {
+	case *ratelimiter.SlidingWindowRateLimiter:
+		return limiter.Reconfigured(limiterConfig, s)
+	case *ratelimiter.CountMinRateLimiter:
+		return limiter.Reconfigured(limiterConfig)
+	}
+
+	limiter := newLimiter(cfg, limiterConfig, observers)
+	setSchedule(limiter, s)
+	return limiter
+}
+
+// newPolicy builds the limiters from the configuration. The state of the clients is carried over from the limiters of the
+// previous policy if their identity didn't change. The previous policy and its limiters are left intact.
+func newPolicy(cfg config.Config, previous *policy, observers []ratelimiter.Observer) *policy {
+	p := newLimiterPolicy(cfg.Limiter, previous, observers)
+	p.ruleLimiters = map[string]ratelimiter.Limiter{}
+	reuse := previous != nil && previous.identity == p.identity
+
+	// Every rule has its own counters, which are kept on reload if the rule name didn't change
+	ruleList := []rules.Rule{}
+	for _, ruleConfig := range cfg.Rules {
+		limiterConfig := newLimiterConfig(cfg.Limiter, ruleConfig.Limit, ruleConfig.Duration)
+		var previousLimiter ratelimiter.Limiter
+		if reuse {
+			previousLimiter = previous.ruleLimiters[ruleConfig.Name]
+		}
+
+		ruleLimiter := reconfigureLimiter(cfg.Limiter, previousLimiter, limiterConfig, newSchedule(ruleConfig.Schedules, limiterConfig), observers)
+		p.ruleLimiters[ruleConfig.Name] = ruleLimiter
+
+		rule := rules.Rule{
+			Name:        ruleConfig.Name,
+			Methods:     ruleConfig.Methods,
+			Path:        ruleConfig.Path,
+			Headers:     ruleConfig.Headers,
+			Tiers:       ruleConfig.Tiers,
+			Limiter:     ruleLimiter,
+			Expressions: compileExpressions(ruleConfig.Expressions),
+			Priority:    ruleConfig.Priority,
+		}
+
+		// Validated with the configuration
+		for _, source := range ruleConfig.Sources {
+			prefix, _ := netip.ParsePrefix(source)
+			rule.Sources = append(rule.Sources, prefix)
+		}
+
+		if ruleConfig.Shadow {
+			shadowLimiter := ratelimiter.NewShadowLimiter(ruleConfig.Name, ruleLimiter, nil)
+			p.shadowLimiters = append(p.shadowLimiters, shadowLimiter)
+			rule.Limiter = shadowLimiter
+		}
+
+		ruleList = append(ruleList, rule)
+	}
+
+	tiers := []rules.Tier{}
+	for _, tierConfig := range cfg.Tiers {
+		clients, _ := access.NewList(tierConfig.Clients...)
+		tiers = append(tiers, rules.Tier{Name: tierConfig.Name, Clients: clients})
+	}
+
+	p.engine, _ = rules.NewEngine(ruleList, tiers)
+	p.expressions = compileExpressions(cfg.Limiter.Expressions)
+	return p
+}
+
+// newSchedule creates the schedule validated with the configuration. Returns nil if there are no periods.
+// The periods without a duration use the duration of the policy. The alignment of the windows is inherited from the policy.
+func newSchedule(cfg []config.Schedule, base ratelimiter.Config) ratelimiter.Schedule {
+	if len(cfg) == 0 {
+		return nil
+	}
+
+	periods := []schedule.Period{}
+	for _, periodConfig := range cfg {
+		limiterConfig := ratelimiter.Config{Limit: periodConfig.Limit, Duration: periodConfig.Duration, Aligned: base.Aligned}
+		if limiterConfig.Duration == 0 {
+			limiterConfig.Duration = base.Duration
+		}
+
+		location, _ := time.LoadLocation(periodConfig.Timezone)
+		if periodConfig.Cron != "" {
+			period, _ := schedule.NewCron(periodConfig.Name, limiterConfig, periodConfig.Cron, periodConfig.For, location)
+			periods = append(periods, period)
+			continue
+		}
+
+		days := []time.Weekday{}
+		for _, day := range periodConfig.Days {
+			weekday, _ := schedule.ParseWeekday(day)
+			days = append(days, weekday)
+		}
+
+		from, _ := schedule.ParseTimeOfDay(periodConfig.From)
+		to, _ := schedule.ParseTimeOfDay(periodConfig.To)
+		periods = append(periods, schedule.NewTimeRange(periodConfig.Name, limiterConfig, days, from, to, location))
+	}
+
+	s, err := schedule.New(periods...)
+	if err != nil {
+		return nil
+	}
+
+	return s
+}
+
+// setSchedule sets the schedule of the limiter, if the limiter supports schedules.
+func setSchedule(limiter ratelimiter.Limiter, s ratelimiter.Schedule) {
+	if scheduled, ok := limiter.(interface{ SetSchedule(ratelimiter.Schedule) }); ok {
+		scheduled.SetSchedule(s)
+	}
+}
+
+// compileExpressions compiles the expressions validated with the configuration. Returns nil if none of the expressions are set.
+func compileExpressions(cfg config.Expressions) *expressions.Expressions {
+	if cfg == (config.Expressions{}) {
+		return nil
+	}
+
+	compiled, _ := expressions.Compile(expressions.Source{Key: cfg.Key, Cost: cfg.Cost, Limit: cfg.Limit})
+	return compiled
+}
+
+// newLimiterPolicy builds the global limiters from the configuration.
+func newLimiterPolicy(cfg config.Limiter, previous *policy, observers []ratelimiter.Observer) *policy {
+	p := &policy{identity: limiterIdentity(cfg)}
+	reuse := previous != nil && previous.identity == p.identity
+
+	var previousEnforcing, previousCandidate ratelimiter.Limiter
+	if reuse {
+		previousEnforcing, previousCandidate = previous.enforcing, previous.candidate
+	}
+
+	enforcingConfig := newLimiterConfig(cfg, cfg.Limit, cfg.Duration)
+	p.enforcing = reconfigureLimiter(cfg, previousEnforcing, enforcingConfig, newSchedule(cfg.Schedules, enforcingConfig), observers)
+	p.limiter = p.enforcing
+
+	// Evaluate the limits in shadow mode
+	if cfg.Shadow {
+		shadowLimiter := ratelimiter.NewShadowLimiter("default", p.enforcing, nil)
+		p.shadowLimiters = append(p.shadowLimiters, shadowLimiter)
+		p.limiter = shadowLimiter
+	}
+
+	// Evaluate the candidate limits side by side with the enforcing limits
+	if cfg.Candidate != nil {
+		candidateConfig := newLimiterConfig(cfg, cfg.Candidate.Limit, cfg.Candidate.Duration)
+		p.candidate = reconfigureLimiter(cfg, previousCandidate, candidateConfig, nil, nil)
+
+		shadowLimiter := ratelimiter.NewShadowLimiter("candidate", p.candidate, p.limiter)
+		p.shadowLimiters = append(p.shadowLimiters, shadowLimiter)
+		p.limiter = shadowLimiter
+	}
+
+	return p
+}
+
+// policyHolder holds the current policy, which can be replaced on reload.
+type policyHolder struct {
+	mu      sync.RWMutex
+	current *policy
+
+	// observers are registered with the enforcing limiters
+	observers []ratelimiter.Observer
+}
+
+// update builds the policy from the configuration and activates it. The new limits only apply once the swap
+// replaces the policy used by the requests.
+func (h *policyHolder) update(cfg config.Config, swap func(p *policy)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	p := newPolicy(cfg, h.current, h.observers)
+	swap(p)
+	h.current = p
+}
+
+func (h *policyHolder) shadowStats() []ratelimiter.ShadowStats {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	stats := []ratelimiter.ShadowStats{}
+	for _, shadowLimiter := range h.current.shadowLimiters {
+		stats = append(stats, shadowLimiter.Stats())
+	}
+
+	return stats
+}
+
+// evictExpired evicts the clients with expired windows from the limiters of the current policy.
+func (h *policyHolder) evictExpired() {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for _, limiter := range h.current.limiters() {
+		if evictable, ok := limiter.(interface{ EvictExpired() int }); ok {
+			evictable.EvictExpired()
+		}
+	}
+}
+
+// inspector returns the enforcing limiter of the current policy, if it exposes the per-client state.
+func (h *policyHolder) inspector() ratelimiter.Inspector {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	inspector, _ := h.current.enforcing.(ratelimiter.Inspector)
+	return inspector
+}
+
+// trackedClients returns the number of clients tracked by the enforcing limiters of the current policy.
+func (h *policyHolder) trackedClients() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	tracked := 0
+	limiters := []ratelimiter.Limiter{h.current.enforcing}
+	for _, limiter := range h.current.ruleLimiters {
+		limiters = append(limiters, limiter)
+	}
+
+	for _, limiter := range limiters {
+		if tracking, ok := limiter.(interface{ TrackedClients() int }); ok {
+			tracked += tracking.TrackedClients()
+		}
+	}
+
+	return tracked
+}
@@ -4,8 +4,10 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/pkg/errors"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/pow"
 	"go.uber.org/zap"
 )
 
@@ -17,6 +19,9 @@ type httpClient struct {
 	logger     *zap.Logger
 	httpClient *http.Client
 	url        string
+
+	// solveChallenges solves the proof-of-work challenge of a limited request and sends the request again
+	solveChallenges bool
 }
 
 // newHttpClient creates a client.
@@ -34,15 +39,29 @@ func newHttpClient(id, url string) *httpClient {
 func (c *httpClient) SendRequest(ctx context.Context) error {
 	c.logger.Info("Sending request", zap.String("url", c.url))
 
-	// Create and send the request
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	resp, err := c.send(ctx, nil)
 	if err != nil {
-		return errors.Wrap(err, "failed to create request")
+		return err
 	}
 
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return errors.Wrap(err, "failed to send request")
+	// Solve the challenge to be admitted over the limit
+	token := resp.Header.Get(pow.HeaderChallenge)
+	if resp.StatusCode == http.StatusTooManyRequests && c.solveChallenges && token != "" {
+		difficulty, err := strconv.Atoi(resp.Header.Get(pow.HeaderDifficulty))
+		if err != nil {
+			return errors.Wrap(err, "invalid challenge difficulty")
+		}
+
+		c.logger.Info("Solving challenge", zap.Int("difficulty", difficulty))
+		solution, err := pow.Solve(ctx, token, difficulty)
+		if err != nil {
+			return errors.Wrap(err, "failed to solve challenge")
+		}
+
+		resp, err = c.send(ctx, http.Header{pow.HeaderChallenge: {token}, pow.HeaderSolution: {solution}})
+		if err != nil {
+			return err
+		}
 	}
 
 	switch resp.StatusCode {
@@ -54,3 +73,23 @@ func (c *httpClient) SendRequest(ctx context.Context) error {
 		return errors.Errorf("unexpected status code: %d", resp.StatusCode)
 	}
 }
+
+// send sends the request with the headers.
+func (c *httpClient) send(ctx context.Context, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create request")
+	}
+
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to send request")
+	}
+	_ = resp.Body.Close()
+
+	return resp, nil
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/pow"
 	"go.uber.org/zap"
 )
 
@@ -76,3 +77,39 @@ func Test_httpClient_SendRequest_Failure(t *testing.T) {
 	err := newHttpClient(clientName, svr.URL).SendRequest(ctx)
 	assert.EqualError(t, err, "unexpected status code: 409")
 }
+
+func Test_httpClient_SendRequest_Challenge(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	zap.ReplaceGlobals(logger)
+
+	token := "challenge-token"
+	requests := 0
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		solution := r.Header.Get(pow.HeaderSolution)
+		if r.Header.Get(pow.HeaderChallenge) == token && pow.LeadingZeros(token, solution) >= 8 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		w.Header().Set(pow.HeaderChallenge, token)
+		w.Header().Set(pow.HeaderDifficulty, "8")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer svr.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	// The challenges are only solved if enabled
+	err := newHttpClient("test", svr.URL).SendRequest(ctx)
+	assert.EqualError(t, err, "request limit exceeded")
+	assert.Equal(t, 1, requests)
+
+	c := newHttpClient("test", svr.URL)
+	c.solveChallenges = true
+	err = c.SendRequest(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, requests)
+}
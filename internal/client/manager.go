@@ -20,17 +20,35 @@ type Manager struct {
 
 	// Worker cancellation function
 	cancelFunc context.CancelFunc
+
+	// solveChallenges lets the workers solve the proof-of-work challenges of the limited requests
+	solveChallenges bool
+}
+
+type ManagerOption func(*Manager)
+
+// WithChallengeSolving lets the workers solve the proof-of-work challenges, which the server issues to the limited clients.
+func WithChallengeSolving() ManagerOption {
+	return func(wm *Manager) {
+		wm.solveChallenges = true
+	}
 }
 
 // NewManager creates a new client manager
-func NewManager(url, clientId string) *Manager {
-	return &Manager{
+func NewManager(url, clientId string, opts ...ManagerOption) *Manager {
+	wm := &Manager{
 		clientId:   clientId,
 		url:        url,
 		logger:     zap.L().Named(fmt.Sprintf("manager-%s", clientId)),
 		wg:         &sync.WaitGroup{},
 		cancelFunc: nil,
 	}
+
+	for _, opt := range opts {
+		opt(wm)
+	}
+
+	return wm
 }
 
 // SpawnClients starts the worker manager with the provided number of workers. Each worker runs in its own goroutine.
@@ -46,6 +64,7 @@ func (wm *Manager) SpawnClients(num int) {
 	// Spawn the workers
 	for i := 0; i < num; i++ {
 		workerHttpClient := newHttpClient(wm.clientId, wm.url)
+		workerHttpClient.solveChallenges = wm.solveChallenges
 		w := newWorker(workerHttpClient)
 
 		// Run the worker asynchronously
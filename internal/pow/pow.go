@@ -0,0 +1,48 @@
+// Package pow is the proof-of-work protocol shared by the server issuing the challenges and the clients solving them.
+// The solution of a challenge is a string, for which the SHA-256 hash of "<token>:<solution>" starts with at least
+// the difficulty of zero bits.
+package pow
+
+import (
+	"context"
+	"crypto/sha256"
+	"math/bits"
+	"strconv"
+)
+
+// Headers of the challenge responses and the solved requests
+const (
+	HeaderChallenge  = "X-PoW-Challenge"
+	HeaderDifficulty = "X-PoW-Difficulty"
+	HeaderSolution   = "X-PoW-Solution"
+)
+
+// LeadingZeros returns the number of leading zero bits of the SHA-256 hash of the solution of the challenge.
+func LeadingZeros(token, solution string) int {
+	hash := sha256.Sum256([]byte(token + ":" + solution))
+
+	zeros := 0
+	for _, b := range hash {
+		if b != 0 {
+			return zeros + bits.LeadingZeros8(b)
+		}
+		zeros += 8
+	}
+
+	return zeros
+}
+
+// Solve finds a solution of the challenge with at least the difficulty of leading zero bits. Gives up when the context is done.
+func Solve(ctx context.Context, token string, difficulty int) (string, error) {
+	for counter := uint64(0); ; counter++ {
+		// Check the context only every once in a while, as hashing is cheap
+		if counter%4096 == 0 && ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+
+		solution := strconv.FormatUint(counter, 36)
+		if LeadingZeros(token, solution) >= difficulty {
+			return solution, nil
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package pow
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLeadingZeros(t *testing.T) {
+	// SHA-256 of "token:45" starts with 0x002c
+	assert.Equal(t, 10, LeadingZeros("token", "45"))
+}
+
+func TestSolve(t *testing.T) {
+	solution, err := Solve(context.Background(), "token", 10)
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, LeadingZeros("token", solution), 10)
+
+	// Impossible difficulties are given up on when the context is done
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = Solve(ctx, "token", 256)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
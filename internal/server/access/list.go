@@ -0,0 +1,170 @@
+package access
+
+import (
+	"net/netip"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// ErrInvalidEntry is returned when an entry can't be parsed.
+var ErrInvalidEntry = errors.New("invalid entry")
+
+// List is a runtime-editable list of client IDs, client ID prefixes and IP ranges.
+//
+// Entries are parsed in the following order:
+//   - an IP CIDR (e.g. 10.0.0.0/8) or a single IP address,
+//   - a client ID prefix, ending with an asterisk (e.g. internal-*),
+//   - an exact client ID.
+type List struct {
+	ids      map[string]struct{}
+	prefixes map[string]struct{}
+	networks map[netip.Prefix]struct{}
+
+	mu sync.RWMutex
+}
+
+// NewList creates a new list with the provided entries.
+func NewList(entries ...string) (*List, error) {
+	l := &List{
+		ids:      make(map[string]struct{}),
+		prefixes: make(map[string]struct{}),
+		networks: make(map[netip.Prefix]struct{}),
+	}
+
+	for _, entry := range entries {
+		if err := l.Add(entry); err != nil {
+			return nil, err
+		}
+	}
+
+	return l, nil
+}
+
+// Add adds an entry to the list.
+func (l *List) Add(entry string) error {
+	entry = strings.TrimSpace(entry)
+	if entry == "" || entry == "*" {
+		return errors.Wrapf(ErrInvalidEntry, "%q", entry)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if network, isNetwork := parseNetwork(entry); isNetwork {
+		l.networks[network] = struct{}{}
+	} else if prefix, isPrefix := strings.CutSuffix(entry, "*"); isPrefix {
+		l.prefixes[prefix] = struct{}{}
+	} else {
+		l.ids[entry] = struct{}{}
+	}
+
+	return nil
+}
+
+// Replace replaces all entries of the list. The list is left unchanged if any of the entries is invalid.
+func (l *List) Replace(entries ...string) error {
+	replacement, err := NewList(entries...)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.ids = replacement.ids
+	l.prefixes = replacement.prefixes
+	l.networks = replacement.networks
+	return nil
+}
+
+// Remove removes an entry from the list. Returns false if the entry was not in the list.
+func (l *List) Remove(entry string) bool {
+	entry = strings.TrimSpace(entry)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if network, isNetwork := parseNetwork(entry); isNetwork {
+		_, exists := l.networks[network]
+		delete(l.networks, network)
+		return exists
+	}
+
+	if prefix, isPrefix := strings.CutSuffix(entry, "*"); isPrefix {
+		_, exists := l.prefixes[prefix]
+		delete(l.prefixes, prefix)
+		return exists
+	}
+
+	_, exists := l.ids[entry]
+	delete(l.ids, entry)
+	return exists
+}
+
+// Entries returns all entries of the list in a sorted order.
+func (l *List) Entries() []string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	entries := []string{}
+	for id := range l.ids {
+		entries = append(entries, id)
+	}
+
+	for prefix := range l.prefixes {
+		entries = append(entries, prefix+"*")
+	}
+
+	for network := range l.networks {
+		entries = append(entries, network.String())
+	}
+
+	sort.Strings(entries)
+	return entries
+}
+
+// Matches checks if either the client ID or the IP address is matched by any of the entries.
+func (l *List) Matches(clientID string, ip netip.Addr) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if clientID != "" {
+		if _, exists := l.ids[clientID]; exists {
+			return true
+		}
+
+		for prefix := range l.prefixes {
+			if strings.HasPrefix(clientID, prefix) {
+				return true
+			}
+		}
+	}
+
+	if ip.IsValid() {
+		ip = ip.Unmap()
+		for network := range l.networks {
+			if network.Contains(ip) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// parseNetwork parses a CIDR or a single IP address into a network prefix.
+func parseNetwork(entry string) (netip.Prefix, bool) {
+	if network, err := netip.ParsePrefix(entry); err == nil {
+		return network.Masked(), true
+	}
+
+	if ip, err := netip.ParseAddr(entry); err == nil {
+		ip = ip.Unmap()
+		return netip.PrefixFrom(ip, ip.BitLen()), true
+	}
+
+	return netip.Prefix{}, false
+}
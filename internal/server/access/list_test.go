@@ -0,0 +1,53 @@
+package access
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestList_Matches(t *testing.T) {
+	list, err := NewList("1", "internal-*", "10.0.0.0/8", "192.168.1.1", "::1")
+	assert.NoError(t, err)
+
+	assert.True(t, list.Matches("1", netip.Addr{}))
+	assert.False(t, list.Matches("11", netip.Addr{}))
+	assert.True(t, list.Matches("internal-probe", netip.Addr{}))
+	assert.False(t, list.Matches("external-probe", netip.Addr{}))
+
+	assert.True(t, list.Matches("", netip.MustParseAddr("10.1.2.3")))
+	assert.True(t, list.Matches("", netip.MustParseAddr("::ffff:10.1.2.3")))
+	assert.True(t, list.Matches("", netip.MustParseAddr("192.168.1.1")))
+	assert.False(t, list.Matches("", netip.MustParseAddr("192.168.1.2")))
+	assert.True(t, list.Matches("", netip.MustParseAddr("::1")))
+	assert.False(t, list.Matches("", netip.Addr{}))
+}
+
+func TestList_Edit(t *testing.T) {
+	list, err := NewList()
+	assert.NoError(t, err)
+	assert.Empty(t, list.Entries())
+
+	assert.Error(t, list.Add(""))
+	assert.Error(t, list.Add("*"))
+
+	assert.NoError(t, list.Add("2"))
+	assert.NoError(t, list.Add("bot-*"))
+	assert.NoError(t, list.Add("10.1.2.3/8"))
+	assert.Equal(t, []string{"10.0.0.0/8", "2", "bot-*"}, list.Entries())
+	assert.True(t, list.Matches("bot-1", netip.Addr{}))
+
+	assert.True(t, list.Remove("bot-*"))
+	assert.False(t, list.Remove("bot-*"))
+	assert.True(t, list.Remove("10.0.0.0/8"))
+	assert.True(t, list.Remove("2"))
+	assert.Empty(t, list.Entries())
+	assert.False(t, list.Matches("bot-1", netip.Addr{}))
+
+	// Replace all entries
+	assert.NoError(t, list.Replace("3", "4"))
+	assert.Equal(t, []string{"3", "4"}, list.Entries())
+	assert.Error(t, list.Replace("5", ""))
+	assert.Equal(t, []string{"3", "4"}, list.Entries())
+}
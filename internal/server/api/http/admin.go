@@ -0,0 +1,359 @@
+package http
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/access"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/heavyhitters"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/penalty"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/quota"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/rate-limiter"
+)
+
+var (
+	notFound        = errorResponse{Error: "not found"}
+	invalidEntry    = errorResponse{Error: "invalid entry"}
+	invalidOverride = errorResponse{Error: "invalid override"}
+	unauthorized    = errorResponse{Error: "unauthorized"}
+	invalidQuery    = errorResponse{Error: "invalid query"}
+	notSupported    = errorResponse{Error: "not supported by the limiting algorithm"}
+)
+
+// defaultOverrideTTL is the duration of an override if the TTL is not provided
+const defaultOverrideTTL = time.Hour
+
+// inspectorKey is the context key of the inspector the client management requests are served with
+const inspectorKey = "inspector"
+
+type overrideRequest struct {
+	Limit int `json:"limit"`
+
+	// TTL is the duration of the override (e.g. 10m)
+	TTL string `json:"ttl"`
+}
+
+type accessEntry struct {
+	Entry string `json:"entry"`
+}
+
+type AdminOption func(*AdminHandler)
+
+// WithBanManagement exposes the bans of the penalty box.
+func WithBanManagement(box *penalty.Box) AdminOption {
+	return func(a *AdminHandler) {
+		a.penalties = box
+	}
+}
+
+// WithAccessListManagement exposes the allow and deny lists for runtime editing.
+func WithAccessListManagement(allow, deny *access.List) AdminOption {
+	return func(a *AdminHandler) {
+		a.accessLists = map[string]*access.List{
+			"allow": allow,
+			"deny":  deny,
+		}
+	}
+}
+
+// WithShadowStats exposes the decision counters of the shadow limiters.
+func WithShadowStats(stats func() []rate_limiter.ShadowStats) AdminOption {
+	return func(a *AdminHandler) {
+		a.shadowStats = stats
+	}
+}
+
+// WithReload allows reloading the configuration through the admin API.
+func WithReload(reload func() error) AdminOption {
+	return func(a *AdminHandler) {
+		a.reload = reload
+	}
+}
+
+// WithClientManagement exposes the per-client state of the enforcing limiter.
+func WithClientManagement(inspector func() rate_limiter.Inspector) AdminOption {
+	return func(a *AdminHandler) {
+		a.inspector = inspector
+	}
+}
+
+// WithHeavyHitters exposes the clients with the most requests.
+func WithHeavyHitters(tracker *heavyhitters.Tracker) AdminOption {
+	return func(a *AdminHandler) {
+		a.heavyHitters = tracker
+	}
+}
+
+// WithQuotaUsage exposes the quota usage of the clients.
+func WithQuotaUsage(tracker *quota.Tracker) AdminOption {
+	return func(a *AdminHandler) {
+		a.quotas = tracker
+	}
+}
+
+// AdminHandler exposes operational endpoints for inspecting and managing the rate limiting state.
+type AdminHandler struct {
+	penalties   *penalty.Box
+	accessLists map[string]*access.List
+
+	shadowStats func() []rate_limiter.ShadowStats
+	reload      func() error
+	inspector   func() rate_limiter.Inspector
+
+	heavyHitters *heavyhitters.Tracker
+	quotas       *quota.Tracker
+}
+
+func NewAdminHandler(opts ...AdminOption) *AdminHandler {
+	a := &AdminHandler{}
+
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	return a
+}
+
+// RegisterRoutes registers the endpoints of the configured subsystems.
+func (a *AdminHandler) RegisterRoutes(router gin.IRouter) {
+	if a.penalties != nil {
+		router.GET("/bans", a.ListBans)
+		router.DELETE("/bans/:clientId", a.LiftBan)
+	}
+
+	if a.accessLists != nil {
+		router.GET("/access/:list", a.ListAccessEntries)
+		router.POST("/access/:list", a.AddAccessEntry)
+		router.DELETE("/access/:list", a.RemoveAccessEntry)
+	}
+
+	if a.shadowStats != nil {
+		router.GET("/shadow", a.ShadowStats)
+	}
+
+	if a.reload != nil {
+		router.POST("/reload", a.Reload)
+	}
+
+	if a.inspector != nil {
+		clients := router.Group("/clients", a.requireInspector)
+		clients.GET("", a.ListClients)
+		clients.GET("/:clientId", a.GetClient)
+		clients.POST("/:clientId/reset", a.ResetClient)
+		clients.PUT("/:clientId/override", a.SetOverride)
+		clients.DELETE("/:clientId/override", a.RemoveOverride)
+	}
+
+	if a.heavyHitters != nil {
+		router.GET("/top", a.TopClients)
+	}
+
+	if a.quotas != nil {
+		router.GET("/usage", a.ListUsage)
+		router.GET("/usage/:clientId", a.GetUsage)
+	}
+}
+
+// AdminAuth authenticates the admin requests with a bearer token. All the requests are rejected if the token is empty,
+// so the admin API can't be exposed by a missing configuration.
+func AdminAuth(token string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		provided, isBearer := strings.CutPrefix(ctx.GetHeader("Authorization"), "Bearer ")
+		if !isBearer || token == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, unauthorized)
+			return
+		}
+
+		ctx.Next()
+	}
+}
+
+// ListBans lists all active bans.
+func (a *AdminHandler) ListBans(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, a.penalties.Bans())
+}
+
+// LiftBan lifts the ban of a client.
+func (a *AdminHandler) LiftBan(ctx *gin.Context) {
+	if !a.penalties.Lift(ctx.Param("clientId")) {
+		ctx.JSON(http.StatusNotFound, notFound)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// ListAccessEntries lists the entries of the allow or deny list.
+func (a *AdminHandler) ListAccessEntries(ctx *gin.Context) {
+	list, exists := a.accessLists[ctx.Param("list")]
+	if !exists || list == nil {
+		ctx.JSON(http.StatusNotFound, notFound)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, list.Entries())
+}
+
+// AddAccessEntry adds a client ID, client ID prefix or an IP CIDR to the allow or deny list.
+func (a *AdminHandler) AddAccessEntry(ctx *gin.Context) {
+	list, exists := a.accessLists[ctx.Param("list")]
+	if !exists || list == nil {
+		ctx.JSON(http.StatusNotFound, notFound)
+		return
+	}
+
+	entry := accessEntry{}
+	if err := ctx.ShouldBindJSON(&entry); err != nil {
+		ctx.JSON(http.StatusBadRequest, invalidEntry)
+		return
+	}
+
+	if err := list.Add(entry.Entry); err != nil {
+		ctx.JSON(http.StatusBadRequest, invalidEntry)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// RemoveAccessEntry removes the entry, provided as a query parameter, from the allow or deny list.
+func (a *AdminHandler) RemoveAccessEntry(ctx *gin.Context) {
+	list, exists := a.accessLists[ctx.Param("list")]
+	if !exists || list == nil || !list.Remove(ctx.Query("entry")) {
+		ctx.JSON(http.StatusNotFound, notFound)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// ShadowStats lists the decision counters of the shadow limiters.
+func (a *AdminHandler) ShadowStats(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, a.shadowStats())
+}
+
+// Reload reloads the configuration.
+func (a *AdminHandler) Reload(ctx *gin.Context) {
+	if err := a.reload(); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// requireInspector rejects the requests if the limiter doesn't expose the per-client state (e.g. the count-min limiter).
+// The inspector is looked up once per request, so a reload switching the algorithm doesn't affect the request.
+func (a *AdminHandler) requireInspector(ctx *gin.Context) {
+	inspector := a.inspector()
+	if inspector == nil {
+		ctx.AbortWithStatusJSON(http.StatusNotImplemented, notSupported)
+		return
+	}
+
+	ctx.Set(inspectorKey, inspector)
+	ctx.Next()
+}
+
+// inspectorOf returns the inspector set by requireInspector.
+func inspectorOf(ctx *gin.Context) rate_limiter.Inspector {
+	return ctx.MustGet(inspectorKey).(rate_limiter.Inspector)
+}
+
+// ListClients lists the state of all clients tracked by the limiter.
+func (a *AdminHandler) ListClients(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, inspectorOf(ctx).Clients())
+}
+
+// GetClient shows the state of a single client.
+func (a *AdminHandler) GetClient(ctx *gin.Context) {
+	state, exists := inspectorOf(ctx).Client(ctx.Param("clientId"))
+	if !exists {
+		ctx.JSON(http.StatusNotFound, notFound)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, state)
+}
+
+// ResetClient resets the client's window.
+func (a *AdminHandler) ResetClient(ctx *gin.Context) {
+	if !inspectorOf(ctx).Reset(ctx.Param("clientId")) {
+		ctx.JSON(http.StatusNotFound, notFound)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// SetOverride sets a temporary limit for the client.
+func (a *AdminHandler) SetOverride(ctx *gin.Context) {
+	request := overrideRequest{}
+	if err := ctx.ShouldBindJSON(&request); err != nil || request.Limit < 0 {
+		ctx.JSON(http.StatusBadRequest, invalidOverride)
+		return
+	}
+
+	ttl := defaultOverrideTTL
+	if request.TTL != "" {
+		var err error
+		ttl, err = time.ParseDuration(request.TTL)
+		if err != nil || ttl <= 0 {
+			ctx.JSON(http.StatusBadRequest, invalidOverride)
+			return
+		}
+	}
+
+	inspectorOf(ctx).SetOverride(ctx.Param("clientId"), request.Limit, ttl)
+	ctx.Status(http.StatusNoContent)
+}
+
+// RemoveOverride removes the client's temporary limit.
+func (a *AdminHandler) RemoveOverride(ctx *gin.Context) {
+	if !inspectorOf(ctx).RemoveOverride(ctx.Param("clientId")) {
+		ctx.JSON(http.StatusNotFound, notFound)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// TopClients lists the clients with the most requests. The number of clients (n) and the window can be set with query parameters.
+func (a *AdminHandler) TopClients(ctx *gin.Context) {
+	n, err := strconv.Atoi(ctx.DefaultQuery("n", "10"))
+	if err != nil || n < 1 {
+		ctx.JSON(http.StatusBadRequest, invalidQuery)
+		return
+	}
+
+	window := a.heavyHitters.MaxWindow()
+	if value, isSet := ctx.GetQuery("window"); isSet {
+		window, err = time.ParseDuration(value)
+		if err != nil || window <= 0 {
+			ctx.JSON(http.StatusBadRequest, invalidQuery)
+			return
+		}
+	}
+
+	ctx.JSON(http.StatusOK, a.heavyHitters.Top(n, window))
+}
+
+// ListUsage lists the quota usage of all clients.
+func (a *AdminHandler) ListUsage(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, a.quotas.Usages())
+}
+
+// GetUsage shows the quota usage of a single client.
+func (a *AdminHandler) GetUsage(ctx *gin.Context) {
+	usage, exists := a.quotas.Usage(ctx.Param("clientId"))
+	if !exists {
+		ctx.JSON(http.StatusNotFound, notFound)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, usage)
+}
@@ -0,0 +1,328 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/access"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/heavyhitters"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/penalty"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/quota"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/rate-limiter"
+	"go.uber.org/zap"
+)
+
+func TestAdminHandler_Bans(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	zap.ReplaceGlobals(logger)
+
+	box := penalty.NewBox(penalty.WithThreshold(1))
+	box.RecordLimited("1")
+	box.RecordLimited("1")
+
+	r := gin.New()
+	NewAdminHandler(WithBanManagement(box)).RegisterRoutes(r.Group("/admin"))
+
+	// List the bans
+	req, _ := http.NewRequest(http.MethodGet, "/admin/bans", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	bans := []penalty.Ban{}
+	err := json.Unmarshal(w.Body.Bytes(), &bans)
+	assert.NoError(t, err)
+	assert.Len(t, bans, 1)
+	assert.Equal(t, "1", bans[0].ClientID)
+
+	// Lift the ban
+	req, _ = http.NewRequest(http.MethodDelete, "/admin/bans/1", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	// Lifting a non-existing ban
+	req, _ = http.NewRequest(http.MethodDelete, "/admin/bans/1", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestAdminHandler_AccessLists(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	zap.ReplaceGlobals(logger)
+
+	allowList, _ := access.NewList()
+	denyList, _ := access.NewList()
+
+	r := gin.New()
+	NewAdminHandler(WithAccessListManagement(allowList, denyList)).RegisterRoutes(r.Group("/admin"))
+
+	// Add an entry
+	req, _ := http.NewRequest(http.MethodPost, "/admin/access/deny", strings.NewReader(`{"entry": "10.0.0.0/8"}`))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	// Add an invalid entry
+	req, _ = http.NewRequest(http.MethodPost, "/admin/access/deny", strings.NewReader(`{"entry": ""}`))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Unknown list
+	req, _ = http.NewRequest(http.MethodGet, "/admin/access/unknown", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// List the entries
+	req, _ = http.NewRequest(http.MethodGet, "/admin/access/deny", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	entries := []string{}
+	err := json.Unmarshal(w.Body.Bytes(), &entries)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.0/8"}, entries)
+
+	// Remove the entry
+	req, _ = http.NewRequest(http.MethodDelete, "/admin/access/deny?entry=10.0.0.0/8", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Empty(t, denyList.Entries())
+}
+
+func TestAdminHandler_Reload(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	zap.ReplaceGlobals(logger)
+
+	reloadErr := errors.New("invalid configuration")
+	r := gin.New()
+	NewAdminHandler(WithReload(func() error { return reloadErr })).RegisterRoutes(r.Group("/admin"))
+
+	req, _ := http.NewRequest(http.MethodPost, "/admin/reload", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	reloadErr = nil
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+}
+
+func TestAdminHandler_Clients(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	zap.ReplaceGlobals(logger)
+
+	rateLimiter := rate_limiter.NewSlidingWindowRateLimiter(rate_limiter.WithLimit(1))
+	rateLimiter.IsLimited("1")
+
+	r := gin.New()
+	inspector := func() rate_limiter.Inspector { return rateLimiter }
+	NewAdminHandler(WithClientManagement(inspector)).RegisterRoutes(r.Group("/admin"))
+
+	// List the clients
+	req, _ := http.NewRequest(http.MethodGet, "/admin/clients", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	clients := []rate_limiter.ClientState{}
+	err := json.Unmarshal(w.Body.Bytes(), &clients)
+	assert.NoError(t, err)
+	assert.Len(t, clients, 1)
+	assert.Equal(t, 0, clients[0].Remaining)
+
+	// Override the limit
+	req, _ = http.NewRequest(http.MethodPut, "/admin/clients/1/override", strings.NewReader(`{"limit": 5, "ttl": "10m"}`))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	req, _ = http.NewRequest(http.MethodPut, "/admin/clients/1/override", strings.NewReader(`{"limit": 5, "ttl": "forever"}`))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Show a single client
+	req, _ = http.NewRequest(http.MethodGet, "/admin/clients/1", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	state := rate_limiter.ClientState{}
+	err = json.Unmarshal(w.Body.Bytes(), &state)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, state.Limit)
+	assert.Equal(t, 4, state.Remaining)
+
+	// Reset the window
+	req, _ = http.NewRequest(http.MethodPost, "/admin/clients/1/reset", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	req, _ = http.NewRequest(http.MethodGet, "/admin/clients/1", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// Remove the override
+	req, _ = http.NewRequest(http.MethodDelete, "/admin/clients/1/override", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+}
+
+func TestAdminAuth(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	zap.ReplaceGlobals(logger)
+
+	r := gin.New()
+	admin := r.Group("/admin", AdminAuth("secret"))
+	admin.GET("/test", func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+
+	// Missing token
+	req, _ := http.NewRequest(http.MethodGet, "/admin/test", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// Invalid token
+	req.Header.Set("Authorization", "Bearer invalid")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// Valid token
+	req.Header.Set("Authorization", "Bearer secret")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Without the token, the admin API is closed
+	r = gin.New()
+	admin = r.Group("/admin", AdminAuth(""))
+	NewAdminHandler(WithBanManagement(penalty.NewBox())).RegisterRoutes(admin)
+
+	req, _ = http.NewRequest(http.MethodDelete, "/admin/bans/1", nil)
+	req.Header.Set("Authorization", "Bearer ")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestAdminHandler_TopClients(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	zap.ReplaceGlobals(logger)
+
+	tracker := heavyhitters.NewTracker()
+	tracker.Record("1")
+	tracker.Record("1")
+	tracker.Record("2")
+
+	r := gin.New()
+	NewAdminHandler(WithHeavyHitters(tracker)).RegisterRoutes(r.Group("/admin"))
+
+	req, _ := http.NewRequest(http.MethodGet, "/admin/top?n=1&window=30s", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	top := []heavyhitters.HeavyHitter{}
+	err := json.Unmarshal(w.Body.Bytes(), &top)
+	assert.NoError(t, err)
+	assert.Equal(t, []heavyhitters.HeavyHitter{{ClientID: "1", Count: 2}}, top)
+
+	req, _ = http.NewRequest(http.MethodGet, "/admin/top?window=soon", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestAdminHandler_ClientsNotSupported(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	zap.ReplaceGlobals(logger)
+
+	r := gin.New()
+	inspector := func() rate_limiter.Inspector { return nil }
+	NewAdminHandler(WithClientManagement(inspector)).RegisterRoutes(r.Group("/admin"))
+
+	req, _ := http.NewRequest(http.MethodGet, "/admin/clients", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotImplemented, w.Code)
+}
+
+func TestAdminHandler_ClientsReload(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	zap.ReplaceGlobals(logger)
+
+	// A reload switches to a limiter without the per-client state after the request was admitted
+	r := gin.New()
+	rateLimiter := rate_limiter.NewSlidingWindowRateLimiter()
+	calls := 0
+	inspector := func() rate_limiter.Inspector {
+		calls++
+		if calls > 1 {
+			return nil
+		}
+
+		return rateLimiter
+	}
+	NewAdminHandler(WithClientManagement(inspector)).RegisterRoutes(r.Group("/admin"))
+
+	req, _ := http.NewRequest(http.MethodGet, "/admin/clients", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestAdminHandler_Usage(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	zap.ReplaceGlobals(logger)
+
+	tracker, err := quota.NewTracker(quota.NewFileStore(filepath.Join(t.TempDir(), "quotas.json")), quota.WithDefaultPlan(quota.Plan{Monthly: 10}))
+	assert.NoError(t, err)
+	_, _ = tracker.Consume("1", "default", 3)
+
+	r := gin.New()
+	NewAdminHandler(WithQuotaUsage(tracker)).RegisterRoutes(r.Group("/admin"))
+
+	req, _ := http.NewRequest(http.MethodGet, "/admin/usage/1", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	usage := quota.Usage{}
+	err = json.Unmarshal(w.Body.Bytes(), &usage)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, usage.Monthly.Used)
+	assert.Equal(t, 7, *usage.Monthly.Remaining)
+
+	req, _ = http.NewRequest(http.MethodGet, "/admin/usage", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	usages := []quota.Usage{}
+	err = json.Unmarshal(w.Body.Bytes(), &usages)
+	assert.NoError(t, err)
+	assert.Len(t, usages, 1)
+
+	req, _ = http.NewRequest(http.MethodGet, "/admin/usage/2", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -1,41 +1,685 @@
 package http
 
 import (
+	"cmp"
+	"context"
 	"net/http"
+	"net/netip"
+	"strconv"
+	"sync/atomic"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/pow"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/access"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/challenge"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/expressions"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/fairqueue"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/heavyhitters"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/keys"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/metrics"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/penalty"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/quota"
 	"github.com/xBlaz3kx/rate-limiter-example/internal/server/rate-limiter"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/rules"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/shedding"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/tarpit"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
 )
 
 var (
 	rateLimitException = errorResponse{Error: "rate limit exceeded"}
 	badRequest         = errorResponse{Error: "rate limit exceeded"}
+	bannedException    = errorResponse{Error: "client is temporarily banned"}
+	accessDenied       = errorResponse{Error: "access denied"}
+	quotaExceeded      = errorResponse{Error: "quota exceeded"}
+	overloaded         = errorResponse{Error: "server is overloaded"}
+	tooManyInFlight    = errorResponse{Error: "too many requests in flight"}
 )
 
+const (
+	// defaultRule is the metric label of requests evaluated by the global limiter
+	defaultRule = "default"
+	defaultTier = rules.DefaultTier
+)
+
+// Span attributes
+const (
+	attributeDecision  = "rate_limiter.decision"
+	attributeRule      = "rate_limiter.rule"
+	attributeLimited   = "rate_limiter.limited"
+	attributeRemaining = "rate_limiter.remaining"
+	attributeCost      = "rate_limiter.cost"
+	attributePriority  = "rate_limiter.priority"
+	attributeTarpit    = "rate_limiter.tarpit_delay_ms"
+)
+
+var tracer = otel.Tracer("github.com/xBlaz3kx/rate-limiter-example/internal/server/api/http")
+
+// clientStater is implemented by the limiters exposing the client's state
+type clientStater interface {
+	Client(clientID string) (rate_limiter.ClientState, bool)
+}
+
 type errorResponse struct {
 	Error string `json:"error"`
 }
 
+// challengeResponse is the response to the limited clients, which can solve a challenge to be admitted
+type challengeResponse struct {
+	errorResponse
+	challenge.Challenge
+}
+
+type HandlerOption func(*Handler)
+
+// WithPolicy replaces the initial policy of the handler, including the limiter.
+func WithPolicy(p Policy) HandlerOption {
+	return func(h *Handler) {
+		h.SetPolicy(p)
+	}
+}
+
+// WithKeyExtractor sets the client ID extraction. By default, the client ID is read from the clientId query parameter.
+func WithKeyExtractor(extractor keys.Extractor) HandlerOption {
+	return func(h *Handler) {
+		h.updatePolicy(func(p *Policy) {
+			p.KeyExtractor = extractor
+		})
+	}
+}
+
+// WithRouteKeyExtractors sets the client ID extraction per route pattern (e.g. /users/:id).
+// Requests to other routes use the default extractor.
+func WithRouteKeyExtractors(routes map[string]keys.Extractor) HandlerOption {
+	return func(h *Handler) {
+		h.updatePolicy(func(p *Policy) {
+			p.RouteKeyExtractors = routes
+		})
+	}
+}
+
+// WithPenaltyBox bans clients that are repeatedly rate limited.
+func WithPenaltyBox(box *penalty.Box) HandlerOption {
+	return func(h *Handler) {
+		h.penalties = box
+	}
+}
+
+// WithAccessLists lets allowlisted clients bypass the limiter and denies the denylisted clients.
+func WithAccessLists(allow, deny *access.List) HandlerOption {
+	return func(h *Handler) {
+		h.allowList = allow
+		h.denyList = deny
+	}
+}
+
+// WithMetrics records the decisions and the limiter latency.
+func WithMetrics(m *metrics.Metrics) HandlerOption {
+	return func(h *Handler) {
+		h.metrics = m
+	}
+}
+
+// WithHeavyHitterTracking records all the requests of the clients, including the denied and the banned ones, to track the heaviest clients.
+func WithHeavyHitterTracking(tracker *heavyhitters.Tracker) HandlerOption {
+	return func(h *Handler) {
+		h.heavyHitters = tracker
+	}
+}
+
+// WithRules evaluates the requests with the rules engine. Requests not matching any rule are evaluated by the global limiter.
+func WithRules(engine *rules.Engine) HandlerOption {
+	return func(h *Handler) {
+		h.updatePolicy(func(p *Policy) {
+			p.Rules = engine
+		})
+	}
+}
+
+// WithQuotas charges the allowed requests to the daily and monthly budgets of the clients.
+func WithQuotas(tracker *quota.Tracker) HandlerOption {
+	return func(h *Handler) {
+		h.quotas = tracker
+	}
+}
+
+// WithExpressions evaluates the expressions for the requests not matching any rule.
+func WithExpressions(e *expressions.Expressions) HandlerOption {
+	return func(h *Handler) {
+		h.updatePolicy(func(p *Policy) {
+			p.Expressions = e
+		})
+	}
+}
+
+// WithLoadShedding sheds the requests over the adaptive server-wide concurrency limit.
+func WithLoadShedding(limiter *shedding.Limiter) HandlerOption {
+	return func(h *Handler) {
+		h.shedder = limiter
+	}
+}
+
+// WithConcurrencyLimit limits the number of requests a client can have in flight at once.
+func WithConcurrencyLimit(limiter *rate_limiter.ConcurrencyLimiter) HandlerOption {
+	return func(h *Handler) {
+		h.concurrency = limiter
+	}
+}
+
+// WithFairQueue makes the allowed requests wait for the capacity of the fair queue, which serves the clients round-robin.
+func WithFairQueue(queue *fairqueue.Queue) HandlerOption {
+	return func(h *Handler) {
+		h.queue = queue
+	}
+}
+
+// WithTarpit holds the responses to the limited and banned clients for a growing delay, instead of responding instantly.
+func WithTarpit(t *tarpit.Tarpit) HandlerOption {
+	return func(h *Handler) {
+		h.tarpit = t
+	}
+}
+
+// WithChallenges issues proof-of-work challenges to the limited clients. A request with a solved challenge is admitted over the limit.
+func WithChallenges(issuer *challenge.Issuer) HandlerOption {
+	return func(h *Handler) {
+		h.challenges = issuer
+	}
+}
+
+// WithPriorityClasses derives the priority of the requests, so the lower priorities are shed first.
+// Without the classes, the requests have the normal priority or the priority of the matched rule.
+func WithPriorityClasses(classes *shedding.Classes) HandlerOption {
+	return func(h *Handler) {
+		h.updatePolicy(func(p *Policy) {
+			p.Classes = classes
+		})
+	}
+}
+
+// Policy is the reloadable part of the handler. The policy is swapped as a whole, so a request is evaluated
+// either with the previous or with the new policy, but never with a mix of both.
+type Policy struct {
+	// Limiter evaluates the requests not matching any rule
+	Limiter rate_limiter.Limiter
+
+	// Rules are evaluated before the global limiter. Nil evaluates all requests with the global limiter.
+	Rules *rules.Engine
+
+	// Expressions of the requests not matching any rule. Nil disables the expressions.
+	Expressions *expressions.Expressions
+
+	// Classes derive the priority of the requests. Nil gives the requests the normal priority or the priority of the matched rule.
+	Classes *shedding.Classes
+
+	// KeyExtractor extracts the client ID of the routes without an extractor. Nil reads the clientId query parameter.
+	KeyExtractor keys.Extractor
+
+	// RouteKeyExtractors extract the client ID per route pattern (e.g. /users/:id)
+	RouteKeyExtractors map[string]keys.Extractor
+}
+
 type Handler struct {
-	limiter *rate_limiter.SlidingWindowRateLimiter
+	policy       atomic.Pointer[Policy]
+	penalties    *penalty.Box
+	quotas       *quota.Tracker
+	shedder      *shedding.Limiter
+	concurrency  *rate_limiter.ConcurrencyLimiter
+	queue        *fairqueue.Queue
+	tarpit       *tarpit.Tarpit
+	challenges   *challenge.Issuer
+	allowList    *access.List
+	denyList     *access.List
+	metrics      *metrics.Metrics
+	heavyHitters *heavyhitters.Tracker
+}
+
+func NewHandler(limiter rate_limiter.Limiter, opts ...HandlerOption) *Handler {
+	h := &Handler{}
+	h.SetPolicy(Policy{Limiter: limiter})
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h
 }
 
-func NewHandler(limiter *rate_limiter.SlidingWindowRateLimiter) *Handler {
-	return &Handler{
-		limiter: limiter,
+// SetPolicy atomically replaces the policy. Requests in flight finish with the policy they started with.
+func (h *Handler) SetPolicy(p Policy) {
+	if p.KeyExtractor == nil {
+		p.KeyExtractor = keys.Query("clientId")
 	}
+
+	h.policy.Store(&p)
+}
+
+// updatePolicy replaces the policy with an updated copy.
+func (h *Handler) updatePolicy(update func(p *Policy)) {
+	p := *h.policy.Load()
+	update(&p)
+	h.SetPolicy(p)
+}
+
+// extractKey extracts the client ID with the extractor of the matched route.
+func extractKey(ctx *gin.Context, p *Policy) (string, bool) {
+	if extractor, exists := p.RouteKeyExtractors[ctx.FullPath()]; exists {
+		return extractor.Extract(ctx)
+	}
+
+	return p.KeyExtractor.Extract(ctx)
+}
+
+// decision is the outcome of the request evaluation
+type decision struct {
+	outcome metrics.Decision
+
+	// rule and tier the request was evaluated with
+	rule string
+	tier string
+
+	// clientId is set once the client ID is known
+	clientId string
+
+	// clientIP is the IP of the client
+	clientIP string
+
+	// ban is set for banned clients
+	ban *penalty.Ban
+
+	// usage is set for clients with exhausted quotas
+	usage *quota.Usage
+
+	// challenge is set for limited clients, if the challenges are enabled
+	challenge *challenge.Challenge
+
+	// priority of the request and the release of its slots (admission, queue, concurrency), which must be called when the request completes
+	priority shedding.Priority
+	release  func()
+
+	// latency is the time the limiter needed to make the decision
+	latency time.Duration
 }
 
 func (h *Handler) HandleRequest(ctx *gin.Context) {
-	clientId, isFound := ctx.GetQuery("clientId")
+	d := h.decide(ctx)
+
+	trace.SpanFromContext(ctx.Request.Context()).SetAttributes(
+		attribute.String(attributeDecision, string(d.outcome)),
+		attribute.String(attributeRule, d.rule),
+		attribute.String(attributePriority, d.priority.String()),
+	)
+
+	if h.metrics != nil {
+		h.metrics.ObserveDecision(d.rule, d.tier, d.outcome, d.latency)
+	}
+
+	// The requests without a client ID are tracked by the IP
+	if h.heavyHitters != nil {
+		h.heavyHitters.Record(cmp.Or(d.clientId, d.clientIP))
+	}
+
+	// The held connections of the limited and banned clients don't take up the capacity of the server, so their slots
+	// are released before the hold. The other requests keep their slots until they complete.
+	isPunished := d.outcome == metrics.DecisionLimited || d.outcome == metrics.DecisionBanned
+	if h.tarpit != nil && isPunished {
+		d.release()
+	} else {
+		defer d.release()
+	}
+
+	if h.tarpit != nil {
+		h.slowDown(ctx, d)
+	}
+
+	switch d.outcome {
+	case metrics.DecisionDenied:
+		ctx.JSON(http.StatusForbidden, accessDenied)
+	case metrics.DecisionBadRequest:
+		ctx.JSON(http.StatusBadRequest, badRequest)
+	case metrics.DecisionBanned:
+		banResponse(ctx, *d.ban)
+	case metrics.DecisionLimited:
+		if d.challenge != nil {
+			ctx.Header(pow.HeaderChallenge, d.challenge.Token)
+			ctx.Header(pow.HeaderDifficulty, strconv.Itoa(d.challenge.Difficulty))
+			ctx.JSON(http.StatusTooManyRequests, challengeResponse{errorResponse: rateLimitException, Challenge: *d.challenge})
+			break
+		}
+
+		ctx.JSON(http.StatusTooManyRequests, rateLimitException)
+	case metrics.DecisionQuotaExceeded:
+		retryAfter(ctx, d.usage.ResetsAt())
+		ctx.JSON(http.StatusTooManyRequests, quotaExceeded)
+	case metrics.DecisionTooManyInFlight:
+		ctx.JSON(http.StatusTooManyRequests, tooManyInFlight)
+	case metrics.DecisionShed, metrics.DecisionQueueFull, metrics.DecisionQueueTimeout:
+		ctx.Header("Retry-After", "1")
+		ctx.JSON(http.StatusServiceUnavailable, overloaded)
+	default:
+		ctx.JSON(http.StatusNoContent, nil)
+	}
+}
+
+// admit admits the request within the adaptive concurrency limit. The admission is released together with the other
+// slots of the request. Returns false if the request is shed.
+func (h *Handler) admit(d *decision) bool {
+	if h.shedder == nil {
+		return true
+	}
+
+	release, ok := h.shedder.Acquire(d.priority)
+	if !ok {
+		d.outcome = metrics.DecisionShed
+		return false
+	}
+
+	slots := d.release
+	d.release = func() {
+		release()
+		slots()
+	}
+
+	return true
+}
+
+// priority derives the priority of the request with the priority classes of the policy.
+func priority(p *Policy, req shedding.Request) shedding.Priority {
+	classes := p.Classes
+	if classes == nil {
+		classes = &shedding.Classes{Default: shedding.PriorityNormal}
+	}
+
+	return classes.Priority(req)
+}
+
+// decide evaluates the request against the server load, the access lists, the bans and the limiter.
+func (h *Handler) decide(ctx *gin.Context) decision {
+	d := decision{rule: defaultRule, tier: defaultTier, release: func() {}}
+	current := h.policy.Load()
+	clientId, isFound := extractKey(ctx, current)
+	d.clientIP = ctx.ClientIP()
+	clientIP, _ := netip.ParseAddr(d.clientIP)
+
+	// The first matching rule replaces the global limiter and expressions
+	limiter := current.Limiter
+	policy := current.Expressions
+	rulePriority := ""
+	if engine := current.Rules; engine != nil {
+		rule, tier, matched := engine.Match(rules.Request{
+			Method:   ctx.Request.Method,
+			Path:     ctx.Request.URL.Path,
+			Header:   ctx.Request.Header,
+			ClientID: clientId,
+			IP:       clientIP,
+		})
+
+		d.tier = tier
+		if matched {
+			d.rule = rule.Name
+			limiter = rule.Limiter
+			policy = rule.Expressions
+			rulePriority = rule.Priority
+		}
+	}
+
+	// The admitted requests are shed over the adaptive concurrency limit, starting with the lowest priorities
+	d.priority = priority(current, shedding.Request{
+		Header:       ctx.Request.Header,
+		ClientID:     clientId,
+		IP:           clientIP,
+		Tier:         d.tier,
+		RulePriority: rulePriority,
+	})
+
+	// The expressions compute the client ID, the cost and the limit of the request
+	cost, limit := 1, 0
+	if policy != nil {
+		result, err := policy.Evaluate(expressions.Request{
+			Method:   ctx.Request.Method,
+			Path:     ctx.Request.URL.Path,
+			Route:    ctx.FullPath(),
+			Header:   ctx.Request.Header,
+			Query:    ctx.Request.URL.Query(),
+			ClientID: clientId,
+			IP:       d.clientIP,
+			Tier:     d.tier,
+			Rule:     d.rule,
+			BodySize: ctx.Request.ContentLength,
+			Time:     time.Now(),
+		})
+
+		// Fall back to the extracted client ID and the configured limit
+		if err != nil {
+			trace.SpanFromContext(ctx.Request.Context()).RecordError(err)
+			zap.L().Warn("Failed to evaluate the expressions", zap.String("rule", d.rule), zap.Error(err))
+		} else {
+			if result.Key != "" {
+				clientId, isFound = result.Key, true
+			}
+
+			cost, limit = result.Cost, result.Limit
+		}
+	}
+
+	d.clientId = clientId
+
+	// Access lists are evaluated before the limiter and don't consume the limiter state
+	if h.denyList != nil && h.denyList.Matches(clientId, clientIP) {
+		d.outcome = metrics.DecisionDenied
+		return d
+	}
+
+	if h.allowList != nil && h.allowList.Matches(clientId, clientIP) {
+		d.outcome = metrics.DecisionBypassed
+		h.admit(&d)
+		return d
+	}
+
+	if !isFound || clientId == "" {
+		d.outcome = metrics.DecisionBadRequest
+		return d
+	}
+
+	// Banned clients don't consume the limiter state
+	if h.penalties != nil {
+		if ban, isBanned := h.penalties.IsBanned(clientId); isBanned {
+			d.outcome = metrics.DecisionBanned
+			d.ban = &ban
+			return d
+		}
+	}
+
+	// The slot is held until the request completes, so slow requests of a client don't exhaust the server
+	if !h.acquire(clientId, &d) {
+		d.outcome = metrics.DecisionTooManyInFlight
+		return d
+	}
+
+	// A solved challenge admits the request over the limit
+	isSolved := h.isSolved(ctx, clientId)
+	isLimited := !isSolved && h.isLimited(ctx.Request.Context(), limiter, clientId, cost, limit, &d)
+
+	if !isLimited {
+		d.outcome = metrics.DecisionAllowed
+		if isSolved {
+			d.outcome = metrics.DecisionChallengeSolved
+		}
+
+		// Wait for the capacity, so a client with many concurrent requests can't crowd out the other clients
+		if !h.enqueue(ctx.Request.Context(), clientId, cost, &d) {
+			return d
+		}
+
+		// Admit the request once it leaves the queue, so the time spent waiting doesn't count as the latency of the server
+		if !h.admit(&d) {
+			return d
+		}
+
+		// Only the requests allowed by the limiter are charged to the quota
+		if h.quotas != nil {
+			if usage, ok := h.quotas.Consume(clientId, d.tier, cost); !ok {
+				d.outcome = metrics.DecisionQuotaExceeded
+				d.usage = &usage
+			}
+		}
+
+		return d
+	}
+
+	d.outcome = metrics.DecisionLimited
+	if h.penalties != nil {
+		if ban, isBanned := h.penalties.RecordLimited(clientId); isBanned {
+			d.outcome = metrics.DecisionBanned
+			d.ban = &ban
+			return d
+		}
+	}
+
+	if h.challenges != nil {
+		c := h.challenges.Issue(clientId)
+		d.challenge = &c
+	}
+
+	return d
+}
+
+// isSolved verifies the solution of the challenge carried by the request, if any.
+func (h *Handler) isSolved(ctx *gin.Context, clientId string) bool {
+	token := ctx.GetHeader(pow.HeaderChallenge)
+	if h.challenges == nil || token == "" {
+		return false
+	}
+
+	err := h.challenges.Verify(clientId, token, ctx.GetHeader(pow.HeaderSolution))
+	if err != nil {
+		trace.SpanFromContext(ctx.Request.Context()).RecordError(err)
+		return false
+	}
+
+	return true
+}
+
+// slowDown holds the responses to the limited and banned clients, and to the other requests of the recent offenders.
+func (h *Handler) slowDown(ctx *gin.Context, d decision) {
+	var delay time.Duration
+	switch d.outcome {
+	case metrics.DecisionLimited, metrics.DecisionBanned:
+		delay = h.tarpit.Offend(d.clientId)
+	case metrics.DecisionAllowed, metrics.DecisionQuotaExceeded:
+		delay = h.tarpit.Delay(d.clientId)
+	default:
+		return
+	}
+
+	if h.tarpit.Hold(ctx.Request.Context(), delay) {
+		trace.SpanFromContext(ctx.Request.Context()).SetAttributes(attribute.Int64(attributeTarpit, delay.Milliseconds()))
+	}
+}
+
+// enqueue waits for the capacity of the fair queue. The capacity is released together with the other slots of the request.
+// Returns false if the request was not admitted within the bounds of the queue.
+func (h *Handler) enqueue(ctx context.Context, clientId string, cost int, d *decision) bool {
+	if h.queue == nil {
+		return true
+	}
+
+	release, err := h.queue.Acquire(ctx, clientId, cost)
+	if err != nil {
+		d.outcome = metrics.DecisionQueueTimeout
+		if errors.Is(err, fairqueue.ErrQueueFull) {
+			d.outcome = metrics.DecisionQueueFull
+		}
+
+		return false
+	}
+
+	slots := d.release
+	d.release = func() {
+		release()
+		slots()
+	}
+
+	return true
+}
+
+// acquire acquires the client's slot of the concurrency limit. The slot is released together with the other slots of the request.
+// Returns false if the client has too many requests in flight.
+func (h *Handler) acquire(clientId string, d *decision) bool {
+	if h.concurrency == nil {
+		return true
+	}
+
+	release, ok := h.concurrency.Acquire(clientId)
+	if !ok {
+		return false
+	}
+
+	slots := d.release
+	d.release = func() {
+		release()
+		slots()
+	}
+
+	return true
+}
+
+// isLimited checks the limiter in a span, so the latency of the limiter (and its storage) is visible in the traces.
+func (h *Handler) isLimited(ctx context.Context, limiter rate_limiter.Limiter, clientId string, cost, limit int, d *decision) bool {
+	_, span := tracer.Start(ctx, "rate_limiter.IsLimited", trace.WithAttributes(
+		attribute.String(attributeRule, d.rule),
+		attribute.Int(attributeCost, cost),
+	))
+	defer span.End()
+
+	start := time.Now()
+	isLimited := rate_limiter.IsLimitedN(limiter, clientId, cost, limit)
+	d.latency = time.Since(start)
+
+	span.SetAttributes(attribute.Bool(attributeLimited, isLimited))
+
+	// Look up the remaining quota only if the span is recorded
+	if span.IsRecording() {
+		if stater, ok := limiter.(clientStater); ok {
+			if state, exists := stater.Client(clientId); exists {
+				span.SetAttributes(attribute.Int(attributeRemaining, state.Remaining))
+			}
+		}
+	}
+
+	return isLimited
+}
+
+// Usage responds with the quota usage of the requesting client.
+func (h *Handler) Usage(ctx *gin.Context) {
+	clientId, isFound := extractKey(ctx, h.policy.Load())
 	if !isFound || clientId == "" {
 		ctx.JSON(http.StatusBadRequest, badRequest)
 		return
 	}
 
-	if h.limiter.IsLimited(clientId) {
-		ctx.JSON(http.StatusTooManyRequests, rateLimitException)
-	} else {
-		ctx.JSON(http.StatusNoContent, nil)
+	usage, exists := h.quotas.Usage(clientId)
+	if !exists {
+		ctx.JSON(http.StatusNotFound, notFound)
+		return
 	}
+
+	ctx.JSON(http.StatusOK, usage)
+}
+
+// banResponse responds with the time until the ban expires.
+func banResponse(ctx *gin.Context, ban penalty.Ban) {
+	retryAfter(ctx, ban.Until)
+	ctx.JSON(http.StatusForbidden, bannedException)
+}
+
+// retryAfter sets the Retry-After header to the seconds until the time, but at least one second.
+func retryAfter(ctx *gin.Context, until time.Time) {
+	seconds := int(time.Until(until).Round(time.Second).Seconds())
+	ctx.Header("Retry-After", strconv.Itoa(max(seconds, 1)))
 }
@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -13,7 +15,24 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/pow"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/access"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/challenge"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/expressions"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/fairqueue"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/heavyhitters"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/keys"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/penalty"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/quota"
 	rate_limiter "github.com/xBlaz3kx/rate-limiter-example/internal/server/rate-limiter"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/rules"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/shedding"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/tarpit"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
 	"go.uber.org/zap"
 )
 
@@ -115,3 +134,654 @@ func TestHandler_ConcurrentRequests(t *testing.T) {
 
 	wg.Wait()
 }
+
+func TestHandler_Banned(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	zap.ReplaceGlobals(logger)
+
+	// Create a rate limiter and a penalty box that bans after the second limited request
+	rateLimiter := rate_limiter.NewSlidingWindowRateLimiter(rate_limiter.WithLimit(1))
+	box := penalty.NewBox(penalty.WithThreshold(1))
+
+	r := gin.New()
+	h := NewHandler(rateLimiter, WithPenaltyBox(box))
+	r.GET("", h.HandleRequest)
+
+	expectedCodes := []int{http.StatusNoContent, http.StatusTooManyRequests, http.StatusForbidden, http.StatusForbidden}
+	for _, expectedCode := range expectedCodes {
+		req, _ := http.NewRequest(http.MethodGet, "/?clientId=1", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, expectedCode, w.Code)
+	}
+
+	// Other clients are not affected
+	req, _ := http.NewRequest(http.MethodGet, "/?clientId=2", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	// The banned client gets a dedicated response
+	req, _ = http.NewRequest(http.MethodGet, "/?clientId=1", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+
+	response := errorResponse{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.EqualValues(t, bannedException, response)
+}
+
+func TestHandler_HeavyHitters(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	zap.ReplaceGlobals(logger)
+
+	rateLimiter := rate_limiter.NewSlidingWindowRateLimiter(rate_limiter.WithLimit(1))
+	box := penalty.NewBox(penalty.WithThreshold(1))
+	denyList, err := access.NewList("bad-actor")
+	assert.NoError(t, err)
+	tracker := heavyhitters.NewTracker()
+
+	r := gin.New()
+	h := NewHandler(rateLimiter, WithPenaltyBox(box), WithAccessLists(nil, denyList), WithHeavyHitterTracking(tracker))
+	r.GET("", h.HandleRequest)
+
+	// The banned, the denied and the requests without a client ID don't reach the limiter, but are tracked
+	requests := []string{"/?clientId=1", "/?clientId=1", "/?clientId=1", "/?clientId=1", "/?clientId=bad-actor", "/?clientId=bad-actor", "/"}
+	for _, url := range requests {
+		req, _ := http.NewRequest(http.MethodGet, url, nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+	}
+
+	assert.Equal(t, []heavyhitters.HeavyHitter{
+		{ClientID: "1", Count: 4},
+		{ClientID: "bad-actor", Count: 2},
+		{ClientID: "192.0.2.1", Count: 1},
+	}, tracker.Top(10, time.Minute))
+}
+
+func TestHandler_AccessLists(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	zap.ReplaceGlobals(logger)
+
+	rateLimiter := rate_limiter.NewSlidingWindowRateLimiter(rate_limiter.WithLimit(1))
+	allowList, err := access.NewList("internal-*", "192.0.2.1")
+	assert.NoError(t, err)
+	denyList, err := access.NewList("bad-actor")
+	assert.NoError(t, err)
+
+	r := gin.New()
+	h := NewHandler(rateLimiter, WithAccessLists(allowList, denyList))
+	r.GET("", h.HandleRequest)
+
+	// Allowlisted clients are never limited
+	for i := 0; i < 3; i++ {
+		req, _ := http.NewRequest(http.MethodGet, "/?clientId=internal-probe", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+	}
+
+	// Allowlisted IPs don't need a client ID
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	// Denylisted clients are denied without consuming the limiter state
+	req, _ = http.NewRequest(http.MethodGet, "/?clientId=bad-actor", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	response := errorResponse{}
+	err = json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.EqualValues(t, accessDenied, response)
+
+	assert.NoError(t, denyList.Add("1"))
+	assert.True(t, denyList.Remove("bad-actor"))
+
+	req, _ = http.NewRequest(http.MethodGet, "/?clientId=bad-actor", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	req, _ = http.NewRequest(http.MethodGet, "/?clientId=1", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestHandler_SetPolicy(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	zap.ReplaceGlobals(logger)
+
+	r := gin.New()
+	h := NewHandler(rate_limiter.NewSlidingWindowRateLimiter(rate_limiter.WithLimit(1)))
+	r.GET("", h.HandleRequest)
+
+	expectedCodes := []int{http.StatusNoContent, http.StatusTooManyRequests}
+	for _, expectedCode := range expectedCodes {
+		req, _ := http.NewRequest(http.MethodGet, "/?clientId=1", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, expectedCode, w.Code)
+	}
+
+	// Replace the limiter
+	h.SetPolicy(Policy{Limiter: rate_limiter.NewSlidingWindowRateLimiter(rate_limiter.WithLimit(2))})
+
+	expectedCodes = []int{http.StatusNoContent, http.StatusNoContent, http.StatusTooManyRequests}
+	for _, expectedCode := range expectedCodes {
+		req, _ := http.NewRequest(http.MethodGet, "/?clientId=1", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, expectedCode, w.Code)
+	}
+}
+
+func TestHandler_RouteKeyExtractors(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	zap.ReplaceGlobals(logger)
+
+	r := gin.New()
+	h := NewHandler(
+		rate_limiter.NewSlidingWindowRateLimiter(rate_limiter.WithLimit(1)),
+		WithKeyExtractor(keys.Header("X-Client-ID")),
+		WithRouteKeyExtractors(map[string]keys.Extractor{
+			"/orders": keys.Composite(keys.Header("X-Client-ID"), keys.Route()),
+		}),
+	)
+	r.GET("/", h.HandleRequest)
+	r.GET("/orders", h.HandleRequest)
+
+	// The routes have separate limits for the same client
+	requests := []struct {
+		path         string
+		expectedCode int
+	}{
+		{path: "/", expectedCode: http.StatusNoContent},
+		{path: "/orders", expectedCode: http.StatusNoContent},
+		{path: "/", expectedCode: http.StatusTooManyRequests},
+		{path: "/orders", expectedCode: http.StatusTooManyRequests},
+	}
+
+	for _, request := range requests {
+		req, _ := http.NewRequest(http.MethodGet, request.path, nil)
+		req.Header.Set("X-Client-ID", "1")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, request.expectedCode, w.Code, request.path)
+	}
+
+	// The query parameter is no longer used
+	req, _ := http.NewRequest(http.MethodGet, "/?clientId=1", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestHandler_Rules(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	zap.ReplaceGlobals(logger)
+
+	engine, err := rules.NewEngine([]rules.Rule{
+		{Name: "login", Methods: []string{http.MethodPost}, Path: "/login", Limiter: rate_limiter.NewSlidingWindowRateLimiter(rate_limiter.WithLimit(1))},
+		{Name: "static", Path: "/static/**", Limiter: rate_limiter.NewSlidingWindowRateLimiter(rate_limiter.WithLimit(3))},
+	}, nil)
+	assert.NoError(t, err)
+
+	r := gin.New()
+	global := rate_limiter.NewSlidingWindowRateLimiter(rate_limiter.WithLimit(2))
+	h := NewHandler(global, WithRules(engine))
+	r.NoRoute(h.HandleRequest)
+
+	requests := []struct {
+		method       string
+		path         string
+		expectedCode int
+	}{
+		{method: http.MethodPost, path: "/login", expectedCode: http.StatusNoContent},
+		{method: http.MethodPost, path: "/login", expectedCode: http.StatusTooManyRequests},
+		// Evaluated by the global limiter
+		{method: http.MethodGet, path: "/login", expectedCode: http.StatusNoContent},
+		{method: http.MethodGet, path: "/search", expectedCode: http.StatusNoContent},
+		{method: http.MethodGet, path: "/search", expectedCode: http.StatusTooManyRequests},
+		{method: http.MethodGet, path: "/static/main.css", expectedCode: http.StatusNoContent},
+		{method: http.MethodGet, path: "/static/main.js", expectedCode: http.StatusNoContent},
+		{method: http.MethodGet, path: "/static/logo.png", expectedCode: http.StatusNoContent},
+		{method: http.MethodGet, path: "/static/favicon.ico", expectedCode: http.StatusTooManyRequests},
+	}
+
+	for _, request := range requests {
+		req, _ := http.NewRequest(request.method, request.path+"?clientId=1", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, request.expectedCode, w.Code, "%s %s", request.method, request.path)
+	}
+
+	// Without the rules, all requests are evaluated by the global limiter
+	h.SetPolicy(Policy{Limiter: global})
+	req, _ := http.NewRequest(http.MethodGet, "/static/main.css?clientId=1", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+}
+
+func TestHandler_Expressions(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	zap.ReplaceGlobals(logger)
+
+	e, err := expressions.Compile(expressions.Source{
+		Key:  `"x-tenant" in headers ? headers["x-tenant"] : client_id`,
+		Cost: `method == "POST" ? 5 : 1`,
+	})
+	assert.NoError(t, err)
+
+	r := gin.New()
+	h := NewHandler(rate_limiter.NewSlidingWindowRateLimiter(rate_limiter.WithLimit(6)), WithExpressions(e))
+	r.Any("", h.HandleRequest)
+
+	requests := []struct {
+		method       string
+		clientId     string
+		expectedCode int
+	}{
+		{method: http.MethodPost, clientId: "1", expectedCode: http.StatusNoContent},
+		{method: http.MethodGet, clientId: "2", expectedCode: http.StatusNoContent},
+		// Both clients are in the same tenant, which used up 6 units
+		{method: http.MethodGet, clientId: "1", expectedCode: http.StatusTooManyRequests},
+	}
+
+	for _, request := range requests {
+		req, _ := http.NewRequest(request.method, "/?clientId="+request.clientId, nil)
+		req.Header.Set("X-Tenant", "acme")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, request.expectedCode, w.Code)
+	}
+
+	// Without the tenant, the client ID is used
+	req, _ := http.NewRequest(http.MethodGet, "/?clientId=1", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+}
+
+func TestHandler_Quotas(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	zap.ReplaceGlobals(logger)
+
+	tracker, err := quota.NewTracker(quota.NewFileStore(filepath.Join(t.TempDir(), "quotas.json")), quota.WithDefaultPlan(quota.Plan{Daily: 2}))
+	assert.NoError(t, err)
+
+	r := gin.New()
+	h := NewHandler(rate_limiter.NewSlidingWindowRateLimiter(rate_limiter.WithLimit(10)), WithQuotas(tracker))
+	r.GET("", h.HandleRequest)
+	r.GET("/usage", h.Usage)
+
+	expectedCodes := []int{http.StatusNoContent, http.StatusNoContent, http.StatusTooManyRequests}
+	for _, expectedCode := range expectedCodes {
+		req, _ := http.NewRequest(http.MethodGet, "/?clientId=1", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, expectedCode, w.Code)
+
+		if expectedCode == http.StatusTooManyRequests {
+			assert.JSONEq(t, `{"error":"quota exceeded"}`, w.Body.String())
+			assert.NotEmpty(t, w.Header().Get("Retry-After"))
+		}
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "/usage?clientId=1", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	usage := quota.Usage{}
+	err = json.Unmarshal(w.Body.Bytes(), &usage)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, usage.Daily.Used)
+	assert.Equal(t, 0, *usage.Daily.Remaining)
+}
+
+func TestHandler_LoadShedding(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	zap.ReplaceGlobals(logger)
+
+	shedder := shedding.NewLimiter(shedding.WithLimits(2, 2))
+	r := gin.New()
+	h := NewHandler(rate_limiter.NewSlidingWindowRateLimiter(rate_limiter.WithLimit(10)), WithLoadShedding(shedder))
+	r.GET("", h.HandleRequest)
+
+	// The request is shed while the server is at its concurrency limit
+	release1, _ := shedder.Acquire(shedding.PriorityCritical)
+	release2, _ := shedder.Acquire(shedding.PriorityCritical)
+
+	req, _ := http.NewRequest(http.MethodGet, "/?clientId=1", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	assert.JSONEq(t, `{"error":"server is overloaded"}`, w.Body.String())
+
+	release1()
+	release2()
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, 0, shedder.InFlight())
+}
+
+func TestHandler_ConcurrencyLimit(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	zap.ReplaceGlobals(logger)
+
+	concurrency := rate_limiter.NewConcurrencyLimiter(1)
+	r := gin.New()
+	h := NewHandler(rate_limiter.NewSlidingWindowRateLimiter(rate_limiter.WithLimit(10)), WithConcurrencyLimit(concurrency))
+	r.GET("", h.HandleRequest)
+
+	request := func(clientId string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodGet, "/?clientId="+clientId, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// The client already has a request in flight
+	release, _ := concurrency.Acquire("1")
+	w := request("1")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.JSONEq(t, `{"error":"too many requests in flight"}`, w.Body.String())
+
+	// Other clients have their own slots
+	assert.Equal(t, http.StatusNoContent, request("2").Code)
+
+	// The slot is released when the request completes
+	release()
+	assert.Equal(t, http.StatusNoContent, request("1").Code)
+	assert.Equal(t, 0, concurrency.TrackedClients())
+}
+
+func TestHandler_PriorityClasses(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	zap.ReplaceGlobals(logger)
+
+	trusted, _ := access.NewList("internal-*")
+	shedder := shedding.NewLimiter(shedding.WithLimits(10, 10), shedding.WithReserved(0.2, 0, 0.6))
+	engine, err := rules.NewEngine([]rules.Rule{
+		{Name: "health", Path: "/health", Limiter: rate_limiter.NewSlidingWindowRateLimiter(), Priority: "critical"},
+	}, nil)
+	assert.NoError(t, err)
+
+	r := gin.New()
+	h := NewHandler(
+		rate_limiter.NewSlidingWindowRateLimiter(rate_limiter.WithLimit(100)),
+		WithLoadShedding(shedder),
+		WithRules(engine),
+		WithPriorityClasses(&shedding.Classes{Default: shedding.PriorityLow, Header: "X-Priority", Trusted: trusted}),
+	)
+	r.NoRoute(h.HandleRequest)
+
+	// Fill the capacity up to the reserved capacity of the critical requests
+	for i := 0; i < 8; i++ {
+		_, ok := shedder.Acquire(shedding.PriorityHigh)
+		assert.True(t, ok)
+	}
+
+	tests := []struct {
+		name         string
+		path         string
+		priority     string
+		expectedCode int
+	}{
+		{name: "low priority", path: "/?clientId=1", expectedCode: http.StatusServiceUnavailable},
+		{name: "untrusted header", path: "/?clientId=1", priority: "critical", expectedCode: http.StatusServiceUnavailable},
+		{name: "trusted header", path: "/?clientId=internal-billing", priority: "critical", expectedCode: http.StatusNoContent},
+		{name: "rule priority", path: "/health?clientId=1", expectedCode: http.StatusNoContent},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, test.path, nil)
+			req.Header.Set("X-Priority", test.priority)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			assert.Equal(t, test.expectedCode, w.Code)
+		})
+	}
+
+	// The admitted requests are released when they complete
+	assert.Equal(t, 8, shedder.InFlight())
+}
+
+func TestHandler_FairQueue(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	zap.ReplaceGlobals(logger)
+
+	queue := fairqueue.NewQueue(fairqueue.WithCapacity(1), fairqueue.WithMaxLength(0))
+	r := gin.New()
+	h := NewHandler(rate_limiter.NewSlidingWindowRateLimiter(rate_limiter.WithLimit(10)), WithFairQueue(queue))
+	r.GET("", h.HandleRequest)
+
+	// The capacity is saturated and no requests can wait
+	release, err := queue.Acquire(context.Background(), "2", 1)
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest(http.MethodGet, "/?clientId=1", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	// The capacity is released when the request completes
+	release()
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, 0, queue.InFlight())
+}
+
+func TestHandler_QueueBeforeAdmission(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	zap.ReplaceGlobals(logger)
+
+	queue := fairqueue.NewQueue(fairqueue.WithCapacity(1), fairqueue.WithMaxLength(10), fairqueue.WithMaxWait(time.Second))
+	shedder := shedding.NewLimiter(shedding.WithLimits(1, 1))
+	r := gin.New()
+	h := NewHandler(
+		rate_limiter.NewSlidingWindowRateLimiter(rate_limiter.WithLimit(10)),
+		WithFairQueue(queue),
+		WithLoadShedding(shedder),
+	)
+	r.GET("", h.HandleRequest)
+
+	// The capacity is saturated, so the request waits in the queue
+	release, err := queue.Acquire(context.Background(), "2", 1)
+	assert.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		req, _ := http.NewRequest(http.MethodGet, "/?clientId=1", nil)
+		r.ServeHTTP(w, req)
+	}()
+
+	// The waiting request is not admitted to the shedder yet
+	assert.Eventually(t, func() bool { return queue.Waiting() == 1 }, time.Second, time.Millisecond)
+	assert.Equal(t, 0, shedder.InFlight())
+
+	release()
+	<-done
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, 0, shedder.InFlight())
+	assert.Equal(t, 0, queue.InFlight())
+}
+
+func TestHandler_Tarpit(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	zap.ReplaceGlobals(logger)
+
+	tarpitted := tarpit.New(tarpit.WithDelay(50*time.Millisecond, time.Second))
+	r := gin.New()
+	h := NewHandler(rate_limiter.NewSlidingWindowRateLimiter(rate_limiter.WithLimit(1)), WithTarpit(tarpitted))
+	r.GET("", h.HandleRequest)
+
+	request := func(clientId string) (int, time.Duration) {
+		req, _ := http.NewRequest(http.MethodGet, "/?clientId="+clientId, nil)
+		w := httptest.NewRecorder()
+		start := time.Now()
+		r.ServeHTTP(w, req)
+		return w.Code, time.Since(start)
+	}
+
+	code, elapsed := request("1")
+	assert.Equal(t, http.StatusNoContent, code)
+	assert.Less(t, elapsed, 50*time.Millisecond)
+
+	// The limited requests are held for a growing delay
+	code, elapsed = request("1")
+	assert.Equal(t, http.StatusTooManyRequests, code)
+	assert.GreaterOrEqual(t, elapsed, 50*time.Millisecond)
+
+	code, elapsed = request("1")
+	assert.Equal(t, http.StatusTooManyRequests, code)
+	assert.GreaterOrEqual(t, elapsed, 100*time.Millisecond)
+
+	// Other clients are not held
+	code, elapsed = request("2")
+	assert.Equal(t, http.StatusNoContent, code)
+	assert.Less(t, elapsed, 50*time.Millisecond)
+	assert.Equal(t, 0, tarpitted.Held())
+}
+
+func TestHandler_TarpitReleasesSlots(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	zap.ReplaceGlobals(logger)
+
+	tarpitted := tarpit.New(tarpit.WithDelay(200*time.Millisecond, time.Second))
+	queue := fairqueue.NewQueue(fairqueue.WithCapacity(2))
+	shedder := shedding.NewLimiter(shedding.WithLimits(2, 2))
+	concurrency := rate_limiter.NewConcurrencyLimiter(5)
+	r := gin.New()
+	h := NewHandler(
+		rate_limiter.NewSlidingWindowRateLimiter(rate_limiter.WithLimit(1)),
+		WithTarpit(tarpitted),
+		WithFairQueue(queue),
+		WithLoadShedding(shedder),
+		WithConcurrencyLimit(concurrency),
+	)
+	r.GET("", h.HandleRequest)
+
+	// hold sends a request of the recent offender and waits until it's held
+	hold := func() (*httptest.ResponseRecorder, chan struct{}) {
+		w := httptest.NewRecorder()
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			req, _ := http.NewRequest(http.MethodGet, "/?clientId=1", nil)
+			r.ServeHTTP(w, req)
+		}()
+
+		assert.Eventually(t, func() bool { return tarpitted.Held() == 1 }, time.Second, time.Millisecond)
+		return w, done
+	}
+
+	tarpitted.Offend("1")
+
+	// The allowed request of a recent offender keeps its slots while it's held
+	w, done := hold()
+	assert.Equal(t, 1, queue.InFlight())
+	assert.Equal(t, 1, shedder.InFlight())
+	assert.Equal(t, 1, concurrency.InFlight("1"))
+
+	<-done
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, 0, queue.InFlight())
+	assert.Equal(t, 0, shedder.InFlight())
+	assert.Equal(t, 0, concurrency.InFlight("1"))
+
+	// The held limited request doesn't take up the capacity of the server
+	w, done = hold()
+	assert.Equal(t, 0, queue.InFlight())
+	assert.Equal(t, 0, shedder.InFlight())
+	assert.Equal(t, 0, concurrency.InFlight("1"))
+
+	<-done
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, 0, tarpitted.Held())
+}
+
+func TestHandler_Challenges(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	zap.ReplaceGlobals(logger)
+
+	r := gin.New()
+	h := NewHandler(
+		rate_limiter.NewSlidingWindowRateLimiter(rate_limiter.WithLimit(1)),
+		WithChallenges(challenge.NewIssuer([]byte("secret"), challenge.WithDifficulty(4, 4))),
+	)
+	r.GET("", h.HandleRequest)
+
+	request := func(token, solution string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodGet, "/?clientId=1", nil)
+		req.Header.Set(pow.HeaderChallenge, token)
+		req.Header.Set(pow.HeaderSolution, solution)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusNoContent, request("", "").Code)
+
+	// The limited client gets a challenge
+	w := request("", "")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "4", w.Header().Get(pow.HeaderDifficulty))
+
+	response := challengeResponse{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "rate limit exceeded", response.Error)
+	assert.Equal(t, w.Header().Get(pow.HeaderChallenge), response.Token)
+
+	solution := 0
+	for pow.LeadingZeros(response.Token, strconv.Itoa(solution)) < 4 {
+		solution++
+	}
+
+	// The solved challenge admits the request once
+	assert.Equal(t, http.StatusNoContent, request(response.Token, strconv.Itoa(solution)).Code)
+	assert.Equal(t, http.StatusTooManyRequests, request(response.Token, strconv.Itoa(solution)).Code)
+}
+
+func TestHandler_Tracing(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	zap.ReplaceGlobals(logger)
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+
+	r := gin.New()
+	h := NewHandler(rate_limiter.NewSlidingWindowRateLimiter(rate_limiter.WithLimit(2)))
+	r.GET("", h.HandleRequest)
+
+	req, _ := http.NewRequest(http.MethodGet, "/?clientId=1", nil)
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	assert.Len(t, spans, 1)
+	assert.Equal(t, "rate_limiter.IsLimited", spans[0].Name())
+	assert.Contains(t, spans[0].Attributes(), attribute.Bool(attributeLimited, false))
+	assert.Contains(t, spans[0].Attributes(), attribute.Int(attributeRemaining, 1))
+	assert.Contains(t, spans[0].Attributes(), attribute.String(attributeRule, defaultRule))
+}
@@ -2,7 +2,11 @@ package http
 
 import (
 	"context"
+	"net"
 	"net/http"
+	"net/http/pprof"
+	"os"
+	"strings"
 	"time"
 
 	ginzap "github.com/gin-contrib/zap"
@@ -13,17 +17,30 @@ import (
 	"go.uber.org/zap"
 )
 
+// unixPrefix is the prefix of the addresses of unix sockets (e.g. unix:/var/run/server.sock)
+const unixPrefix = "unix:"
+
 type Server struct {
 	Router *gin.Engine
 	server *http.Server
 	logger *zap.Logger
 }
 
-func NewServer(address string, logger *zap.Logger) *Server {
+// NewServer creates a server listening on the address. The client IP is only read from the X-Forwarded-For and
+// X-Real-IP headers of the requests coming from the trusted proxies. Without trusted proxies, the IP of the connection is used.
+func NewServer(address string, trustedProxies []string, logger *zap.Logger) (*Server, error) {
 	// Create a Router and attach middleware
 	router := gin.New()
 	router.Use(ginzap.Ginzap(logger, time.RFC3339, true), ginzap.RecoveryWithZap(logger, true))
-	_ = healthcheck.New(router, config.DefaultConfig(), nil)
+
+	// Gin trusts all proxies by default, so any client could spoof its IP
+	if len(trustedProxies) == 0 {
+		trustedProxies = nil
+	}
+
+	if err := router.SetTrustedProxies(trustedProxies); err != nil {
+		return nil, errors.Wrap(err, "invalid trusted proxies")
+	}
 
 	return &Server{
 		Router: router,
@@ -31,15 +48,69 @@ func NewServer(address string, logger *zap.Logger) *Server {
 		server: &http.Server{
 			Addr: address,
 		},
+	}, nil
+}
+
+// Handle registers the handler for the method and the route pattern (e.g. /users/:id). Unlike gin, the patterns
+// conflicting with the registered routes (e.g. /users/:id and /users/:name) are returned as errors instead of panicking.
+func (s *Server) Handle(method, path string, handler gin.HandlerFunc) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.Errorf("%v", r)
+		}
+	}()
+
+	s.Router.Handle(method, path, handler)
+	return nil
+}
+
+// NewAdminServer creates a server for the operational endpoints (health checks, profiling, admin API), which should
+// not be reachable from the internet. The address can be a TCP address or a unix socket (e.g. unix:/var/run/admin.sock).
+func NewAdminServer(address string, logger *zap.Logger, profiling bool) *Server {
+	// The operational endpoints are not reached through the proxies
+	server, _ := NewServer(address, nil, logger)
+	_ = healthcheck.New(server.Router, config.DefaultConfig(), nil)
+
+	if profiling {
+		debug := server.Router.Group("/debug/pprof")
+		debug.GET("/", gin.WrapF(pprof.Index))
+		debug.GET("/cmdline", gin.WrapF(pprof.Cmdline))
+		debug.GET("/profile", gin.WrapF(pprof.Profile))
+		debug.GET("/symbol", gin.WrapF(pprof.Symbol))
+		debug.POST("/symbol", gin.WrapF(pprof.Symbol))
+		debug.GET("/trace", gin.WrapF(pprof.Trace))
+		debug.GET("/:profile", gin.WrapF(pprof.Index))
 	}
+
+	return server
+}
+
+// listen listens on a TCP address or a unix socket.
+func (s *Server) listen() (net.Listener, error) {
+	path, isUnix := strings.CutPrefix(s.server.Addr, unixPrefix)
+	if !isUnix {
+		return net.Listen("tcp", s.server.Addr)
+	}
+
+	// Remove the socket left over from the previous run
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	return net.Listen("unix", path)
 }
 
 // Start starts listening for incoming requests.
 func (s *Server) Start() {
 	s.server.Handler = s.Router.Handler()
 
+	listener, err := s.listen()
+	if err != nil {
+		s.logger.Fatal("Failed to listen", zap.String("address", s.server.Addr), zap.Error(err))
+	}
+
 	go func() {
-		if err := s.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		if err := s.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			s.logger.Fatal("Failed to listen and serve", zap.Error(err))
 		}
 	}()
@@ -0,0 +1,230 @@
+package http
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/access"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/rate-limiter"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/rules"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/shedding"
+	"go.uber.org/zap"
+)
+
+func TestServer_TrustedProxies(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	zap.ReplaceGlobals(logger)
+
+	allowList, err := access.NewList("10.0.0.0/8")
+	assert.NoError(t, err)
+
+	_, err = NewServer(":0", []string{"proxy"}, logger)
+	assert.Error(t, err)
+
+	// Without trusted proxies, the spoofed X-Forwarded-For doesn't match the allowlisted CIDR
+	server, err := NewServer(":0", nil, logger)
+	assert.NoError(t, err)
+	h := NewHandler(rate_limiter.NewSlidingWindowRateLimiter(rate_limiter.WithLimit(1)), WithAccessLists(allowList, nil))
+	server.Router.GET("", h.HandleRequest)
+
+	codes := []int{}
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest(http.MethodGet, "/?clientId=1", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		req.Header.Set("X-Forwarded-For", "10.0.0.1")
+		w := httptest.NewRecorder()
+		server.Router.ServeHTTP(w, req)
+		codes = append(codes, w.Code)
+	}
+	assert.Equal(t, []int{http.StatusNoContent, http.StatusTooManyRequests}, codes)
+
+	// The client IP is read from the headers of the requests coming from the trusted proxies
+	server, err = NewServer(":0", []string{"192.0.2.0/24"}, logger)
+	assert.NoError(t, err)
+	server.Router.GET("", h.HandleRequest)
+
+	req, _ := http.NewRequest(http.MethodGet, "/?clientId=1", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.Header.Set("X-Forwarded-For", "10.0.0.1")
+	w := httptest.NewRecorder()
+	server.Router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+}
+
+func TestAdminServer_UnixSocket(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	zap.ReplaceGlobals(logger)
+
+	socket := filepath.Join(t.TempDir(), "admin.sock")
+	server := NewAdminServer("unix:"+socket, logger, true)
+	server.Start()
+
+	client := http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+			},
+		},
+	}
+
+	resp, err := client.Get("http://admin/healthz")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	_ = resp.Body.Close()
+
+	resp, err = client.Get("http://admin/debug/pprof/goroutine")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	_ = resp.Body.Close()
+
+	assert.NoError(t, server.Shutdown())
+}
+
+func TestServer_SpoofedRuleMatch(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	zap.ReplaceGlobals(logger)
+
+	internal, err := access.NewList("10.0.0.0/8")
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		rule  rules.Rule
+		tiers []rules.Tier
+	}{
+		{
+			name: "sources",
+			rule: rules.Rule{Name: "internal", Sources: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}},
+		},
+		{
+			name:  "tier",
+			rule:  rules.Rule{Name: "internal", Tiers: []string{"internal"}},
+			tiers: []rules.Tier{{Name: "internal", Clients: internal}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.rule.Limiter = rate_limiter.NewSlidingWindowRateLimiter()
+			engine, err := rules.NewEngine([]rules.Rule{test.rule}, test.tiers)
+			assert.NoError(t, err)
+
+			server, err := NewServer(":0", nil, logger)
+			assert.NoError(t, err)
+			h := NewHandler(rate_limiter.NewSlidingWindowRateLimiter(rate_limiter.WithLimit(1)), WithRules(engine))
+			server.Router.GET("", h.HandleRequest)
+
+			// The spoofed IP doesn't match the rule, so the global limiter applies
+			codes := []int{}
+			for i := 0; i < 2; i++ {
+				req, _ := http.NewRequest(http.MethodGet, "/?clientId=1", nil)
+				req.RemoteAddr = "192.0.2.1:1234"
+				req.Header.Set("X-Forwarded-For", "10.0.0.1")
+				req.Header.Set("X-Real-IP", "10.0.0.1")
+				w := httptest.NewRecorder()
+				server.Router.ServeHTTP(w, req)
+				codes = append(codes, w.Code)
+			}
+			assert.Equal(t, []int{http.StatusNoContent, http.StatusTooManyRequests}, codes)
+		})
+	}
+}
+
+func TestServer_SpoofedPriority(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	zap.ReplaceGlobals(logger)
+
+	internal, err := access.NewList("10.0.0.0/8")
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		classes shedding.Classes
+		rules   []rules.Rule
+		tiers   []rules.Tier
+	}{
+		{
+			name:    "trusted",
+			classes: shedding.Classes{Default: shedding.PriorityLow, Header: "X-Priority", Trusted: internal},
+		},
+		{
+			name:    "tier",
+			classes: shedding.Classes{Default: shedding.PriorityLow, Tiers: map[string]shedding.Priority{"internal": shedding.PriorityCritical}},
+			tiers:   []rules.Tier{{Name: "internal", Clients: internal}},
+		},
+		{
+			name:    "rule",
+			classes: shedding.Classes{Default: shedding.PriorityLow},
+			rules: []rules.Rule{{
+				Name:     "internal",
+				Sources:  []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+				Limiter:  rate_limiter.NewSlidingWindowRateLimiter(),
+				Priority: "critical",
+			}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			engine, err := rules.NewEngine(test.rules, test.tiers)
+			assert.NoError(t, err)
+
+			// Fill the capacity up to the reserved capacity of the critical requests
+			shedder := shedding.NewLimiter(shedding.WithLimits(10, 10), shedding.WithReserved(0.2, 0, 0))
+			for i := 0; i < 8; i++ {
+				_, ok := shedder.Acquire(shedding.PriorityHigh)
+				assert.True(t, ok)
+			}
+
+			h := NewHandler(
+				rate_limiter.NewSlidingWindowRateLimiter(rate_limiter.WithLimit(10)),
+				WithRules(engine),
+				WithLoadShedding(shedder),
+				WithPriorityClasses(&test.classes),
+			)
+
+			request := func(trustedProxies []string) int {
+				server, err := NewServer(":0", trustedProxies, logger)
+				assert.NoError(t, err)
+				server.Router.GET("", h.HandleRequest)
+
+				req, _ := http.NewRequest(http.MethodGet, "/?clientId=1", nil)
+				req.RemoteAddr = "192.0.2.1:1234"
+				req.Header.Set("X-Forwarded-For", "10.0.0.1")
+				req.Header.Set("X-Real-IP", "10.0.0.1")
+				req.Header.Set("X-Priority", "critical")
+				w := httptest.NewRecorder()
+				server.Router.ServeHTTP(w, req)
+				return w.Code
+			}
+
+			// The spoofed IP can't claim the critical priority, so the request is shed
+			assert.Equal(t, http.StatusServiceUnavailable, request(nil))
+
+			// The IP forwarded by a trusted proxy gets the critical priority
+			assert.Equal(t, http.StatusNoContent, request([]string{"192.0.2.0/24"}))
+		})
+	}
+}
+
+func TestServer_Handle(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	zap.ReplaceGlobals(logger)
+
+	server, err := NewServer(":0", nil, logger)
+	assert.NoError(t, err)
+
+	handler := func(ctx *gin.Context) {}
+	assert.NoError(t, server.Handle(http.MethodGet, "/users/:id", handler))
+
+	// The conflicting patterns are reported instead of panicking
+	assert.Error(t, server.Handle(http.MethodGet, "/users/:name", handler))
+	assert.Error(t, server.Handle(http.MethodGet, "/users/:id", handler))
+}
@@ -0,0 +1,201 @@
+// Package challenge issues hashcash-style proof-of-work challenges to the clients over their limit.
+// A challenge is a signed token, bound to the client, with a difficulty and an expiry. The challenges are solved
+// with the pow package.
+package challenge
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/pow"
+)
+
+var (
+	ErrInvalid      = errors.New("invalid challenge")
+	ErrExpired      = errors.New("challenge expired")
+	ErrReused       = errors.New("challenge already solved")
+	ErrInsufficient = errors.New("insufficient proof of work")
+)
+
+// Config of the issuer
+type Config struct {
+	// Difficulty is the number of leading zero bits required when the challenges are issued at the target rate
+	Difficulty int
+
+	// MaxDifficulty is the upper bound for the adapted difficulty
+	MaxDifficulty int
+
+	// TargetRate is the number of challenges issued per second, above which the difficulty increases by a bit
+	// with every doubling of the rate
+	TargetRate int
+
+	// TTL is the duration the challenge can be solved in
+	TTL time.Duration
+}
+
+// Challenge is issued to a client over its limit
+type Challenge struct {
+	Token      string    `json:"challenge"`
+	Difficulty int       `json:"difficulty"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+}
+
+// Issuer issues and verifies the challenges.
+type Issuer struct {
+	config Config
+	secret []byte
+
+	// solved is a map of the nonces of the solved challenges to their expiry, so every challenge is solved only once
+	solved   map[string]time.Time
+	prunedAt time.Time
+
+	// issued counts the challenges issued in the current and the previous second
+	issued         int
+	previousIssued int
+	windowStart    time.Time
+
+	mu  sync.Mutex
+	now func() time.Time
+}
+
+// NewIssuer creates an issuer, which signs the challenges with the secret
+func NewIssuer(secret []byte, opts ...Options) *Issuer {
+	i := &Issuer{
+		config: Config{
+			Difficulty:    16,
+			MaxDifficulty: 24,
+			TargetRate:    100,
+			TTL:           time.Minute,
+		},
+		secret: secret,
+		solved: make(map[string]time.Time),
+		now:    time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(i)
+	}
+
+	return i
+}
+
+// Issue issues a challenge to the client. The difficulty adapts to the rate of the issued challenges.
+func (i *Issuer) Issue(clientID string) Challenge {
+	i.mu.Lock()
+	now := i.now()
+	difficulty := i.difficulty(now)
+	i.mu.Unlock()
+
+	nonce := make([]byte, 16)
+	_, _ = rand.Read(nonce)
+
+	expiresAt := now.Add(i.config.TTL).Truncate(time.Second)
+	payload := fmt.Sprintf("%s|%d|%d|%s", hex.EncodeToString(nonce), difficulty, expiresAt.Unix(), clientID)
+	token := base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + base64.RawURLEncoding.EncodeToString(i.sign(payload))
+
+	return Challenge{Token: token, Difficulty: difficulty, ExpiresAt: expiresAt}
+}
+
+// difficulty counts the issued challenge and returns the difficulty adapted to the rate of the issued challenges.
+func (i *Issuer) difficulty(now time.Time) int {
+	switch elapsed := now.Sub(i.windowStart); {
+	case elapsed >= 2*time.Second:
+		i.previousIssued, i.issued = 0, 0
+		i.windowStart = now.Truncate(time.Second)
+	case elapsed >= time.Second:
+		i.previousIssued, i.issued = i.issued, 0
+		i.windowStart = i.windowStart.Add(time.Second)
+	}
+
+	i.issued++
+	rate := max(i.issued, i.previousIssued)
+
+	// Every doubling of the rate doubles the expected work
+	return min(i.config.Difficulty+bits.Len(uint(rate/i.config.TargetRate)), i.config.MaxDifficulty)
+}
+
+// Verify verifies the client's solution of the challenge. A challenge can only be solved once.
+func (i *Issuer) Verify(clientID, token, solution string) error {
+	encodedPayload, encodedSignature, found := strings.Cut(token, ".")
+	if !found {
+		return ErrInvalid
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return ErrInvalid
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil || !hmac.Equal(signature, i.sign(string(payload))) {
+		return ErrInvalid
+	}
+
+	// The client ID is last, as it can contain the separator
+	fields := strings.SplitN(string(payload), "|", 4)
+	if len(fields) != 4 || fields[3] != clientID {
+		return ErrInvalid
+	}
+
+	difficulty, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return ErrInvalid
+	}
+
+	expiry, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return ErrInvalid
+	}
+	expiresAt := time.Unix(expiry, 0)
+
+	if pow.LeadingZeros(token, solution) < difficulty {
+		return ErrInsufficient
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	now := i.now()
+	if !now.Before(expiresAt) {
+		return ErrExpired
+	}
+
+	i.prune(now)
+	nonce := fields[0]
+	if _, isSolved := i.solved[nonce]; isSolved {
+		return ErrReused
+	}
+
+	i.solved[nonce] = expiresAt
+	return nil
+}
+
+// prune forgets the solved challenges, which expired, at most once per second.
+func (i *Issuer) prune(now time.Time) {
+	if now.Sub(i.prunedAt) < time.Second {
+		return
+	}
+	i.prunedAt = now
+
+	for nonce, expiresAt := range i.solved {
+		if !now.Before(expiresAt) {
+			delete(i.solved, nonce)
+		}
+	}
+}
+
+func (i *Issuer) sign(payload string) []byte {
+	mac := hmac.New(sha256.New, i.secret)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,76 @@
+package challenge
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/pow"
+)
+
+// solve finds the solution of the challenge by brute force.
+func solve(c Challenge) string {
+	for counter := 0; ; counter++ {
+		solution := strconv.Itoa(counter)
+		if pow.LeadingZeros(c.Token, solution) >= c.Difficulty {
+			return solution
+		}
+	}
+}
+
+func TestIssuer_Verify(t *testing.T) {
+	now := time.Now()
+	issuer := NewIssuer([]byte("secret"), WithDifficulty(8, 8), WithTTL(time.Minute))
+	issuer.now = func() time.Time { return now }
+
+	c := issuer.Issue("client|1")
+	assert.Equal(t, 8, c.Difficulty)
+	solution := solve(c)
+
+	// The challenge is bound to the client
+	assert.ErrorIs(t, issuer.Verify("client|2", c.Token, solution), ErrInvalid)
+
+	// The solution must have enough leading zero bits
+	insufficient := "0"
+	for counter := 0; pow.LeadingZeros(c.Token, insufficient) >= c.Difficulty; counter++ {
+		insufficient = strconv.Itoa(counter)
+	}
+	assert.ErrorIs(t, issuer.Verify("client|1", c.Token, insufficient), ErrInsufficient)
+
+	// The challenge can only be solved once
+	assert.NoError(t, issuer.Verify("client|1", c.Token, solution))
+	assert.ErrorIs(t, issuer.Verify("client|1", c.Token, solution), ErrReused)
+
+	// Challenges signed with another secret are rejected
+	forged := NewIssuer([]byte("other")).Issue("client|1")
+	assert.ErrorIs(t, issuer.Verify("client|1", forged.Token, solve(forged)), ErrInvalid)
+	assert.ErrorIs(t, issuer.Verify("client|1", "garbage", "0"), ErrInvalid)
+
+	// Expired challenges are rejected
+	c = issuer.Issue("client|1")
+	solution = solve(c)
+	now = now.Add(2 * time.Minute)
+	assert.ErrorIs(t, issuer.Verify("client|1", c.Token, solution), ErrExpired)
+}
+
+func TestIssuer_AdaptiveDifficulty(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	issuer := NewIssuer([]byte("secret"), WithDifficulty(4, 6), WithTargetRate(2))
+	issuer.now = func() time.Time { return now }
+
+	// The difficulty increases by a bit with every doubling of the rate, up to the maximum
+	difficulties := []int{}
+	for i := 0; i < 9; i++ {
+		difficulties = append(difficulties, issuer.Issue("1").Difficulty)
+	}
+	assert.Equal(t, []int{4, 5, 5, 6, 6, 6, 6, 6, 6}, difficulties)
+
+	// The rate of the previous second is kept for a second
+	now = now.Add(time.Second)
+	assert.Equal(t, 6, issuer.Issue("1").Difficulty)
+
+	// The difficulty decreases when the flood stops
+	now = now.Add(5 * time.Second)
+	assert.Equal(t, 4, issuer.Issue("1").Difficulty)
+}
@@ -0,0 +1,42 @@
+package challenge
+
+import (
+	"time"
+)
+
+type Options func(*Issuer)
+
+// WithDifficulty sets the difficulty at the target rate and the upper bound for the adapted difficulty.
+func WithDifficulty(difficulty, maxDifficulty int) Options {
+	return func(i *Issuer) {
+		// The difficulty must be positive and the maximum can't be lower than the difficulty
+		if difficulty < 1 || maxDifficulty < difficulty || maxDifficulty > 256 {
+			return
+		}
+
+		i.config.Difficulty = difficulty
+		i.config.MaxDifficulty = maxDifficulty
+	}
+}
+
+// WithTargetRate sets the number of challenges issued per second, above which the difficulty increases.
+func WithTargetRate(rate int) Options {
+	return func(i *Issuer) {
+		if rate < 1 {
+			return
+		}
+
+		i.config.TargetRate = rate
+	}
+}
+
+// WithTTL sets the duration the challenge can be solved in.
+func WithTTL(ttl time.Duration) Options {
+	return func(i *Issuer) {
+		if ttl < time.Second {
+			return
+		}
+
+		i.config.TTL = ttl
+	}
+}
@@ -0,0 +1,525 @@
+package config
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the configuration of the server
+type Config struct {
+	Server    Server    `yaml:"server"`
+	Limiter   Limiter   `yaml:"limiter"`
+	Key       Key       `yaml:"key"`
+	Routes    []Route   `yaml:"routes"`
+	Rules     []Rule    `yaml:"rules"`
+	Tiers     []Tier    `yaml:"tiers"`
+	Quota     Quota     `yaml:"quota"`
+	Shedding  Shedding  `yaml:"shedding"`
+	Priority  Priority  `yaml:"priority"`
+	Queue     Queue     `yaml:"queue"`
+	Tarpit    Tarpit    `yaml:"tarpit"`
+	Challenge Challenge `yaml:"challenge"`
+	Storage   Storage   `yaml:"storage"`
+	Logging   Logging   `yaml:"logging"`
+	Penalty   Penalty   `yaml:"penalty"`
+	Access    Access    `yaml:"access"`
+	Admin     Admin     `yaml:"admin"`
+	Tracing   Tracing   `yaml:"tracing"`
+}
+
+type Server struct {
+	// Address is the address the public HTTP server listens on
+	Address string `yaml:"address"`
+
+	// TrustedProxies are the IPs and CIDRs of the proxies allowed to set the client IP with the X-Forwarded-For and
+	// X-Real-IP headers. By default, no proxies are trusted and the client IP is the IP of the connection.
+	TrustedProxies []string `yaml:"trustedProxies"`
+}
+
+type Limiter struct {
+	// Algorithm is the rate limiting algorithm. Supported algorithms: sliding-window, count-min
+	Algorithm string `yaml:"algorithm"`
+
+	// Sketch is the error bound of the count-min algorithm
+	Sketch Sketch `yaml:"sketch"`
+
+	// Limit is the maximum number of requests allowed within the duration of the window
+	Limit int `yaml:"limit"`
+
+	// Duration is the duration of the window
+	Duration time.Duration `yaml:"duration"`
+
+	// Concurrency is the maximum number of requests a client can have in flight at once. Zero is unlimited.
+	Concurrency int `yaml:"concurrency"`
+
+	// Alignment of the sliding-window windows. Supported alignments: first-request (the window starts with the client's
+	// first request), clock (the windows align to the wall clock, e.g. every minute or midnight UTC).
+	// The count-min windows are always aligned to the clock.
+	Alignment string `yaml:"alignment"`
+
+	// Shadow evaluates the limits without enforcing them
+	Shadow bool `yaml:"shadow"`
+
+	// Candidate is an optional limit, evaluated in shadow mode side by side with the enforcing limit
+	Candidate *Limit `yaml:"candidate"`
+
+	// Expressions compute the client ID, the cost and the limit of the requests not matching any rule
+	Expressions Expressions `yaml:"expressions"`
+
+	// Schedules replace the limit during their periods. The first active period applies.
+	Schedules []Schedule `yaml:"schedules"`
+}
+
+// Schedule is a recurring period with its own limit. The period is either a daily time range (from, to) on the days
+// of the week, or starts with every activation of the cron expression and lasts for the duration (for).
+type Schedule struct {
+	Name string `yaml:"name"`
+
+	// Days of the week (e.g. mon, tue) of the time range. All days are matched if empty.
+	Days []string `yaml:"days"`
+
+	// From and To are the times of day (HH:MM) of the time range. The range can cross midnight, but must not be empty.
+	From string `yaml:"from"`
+	To   string `yaml:"to"`
+
+	// Cron is the standard cron expression (e.g. 0 2 * * SUN) starting the period
+	Cron string `yaml:"cron"`
+
+	// For is the duration of the period started by the cron expression
+	For time.Duration `yaml:"for"`
+
+	// Timezone of the period (e.g. Europe/Ljubljana). Defaults to UTC.
+	Timezone string `yaml:"timezone"`
+
+	// Limit is the maximum number of requests allowed within the duration of the window
+	Limit int `yaml:"limit"`
+
+	// Duration is the duration of the window. Defaults to the duration of the policy.
+	Duration time.Duration `yaml:"duration"`
+}
+
+// Expressions are CEL expressions evaluated per request. The expressions are compiled and type-checked when the configuration is loaded.
+type Expressions struct {
+	// Key computes the client ID, replacing the extracted client ID (e.g. headers["x-tenant"])
+	Key string `yaml:"key"`
+
+	// Cost computes the units charged for the request (e.g. method == "POST" && (body_size < 0 || body_size > 1048576) ? 5 : 1).
+	// The body size is -1 if the size is unknown (e.g. the chunked bodies).
+	Cost string `yaml:"cost"`
+
+	// Limit computes the limit the request is evaluated against (e.g. tier == "premium" ? 1000 : 100)
+	Limit string `yaml:"limit"`
+}
+
+type Sketch struct {
+	// Epsilon is the maximum overestimation of a client's count, as a fraction of all requests in the window
+	Epsilon float64 `yaml:"epsilon"`
+
+	// Delta is the probability of the overestimation exceeding the epsilon
+	Delta float64 `yaml:"delta"`
+}
+
+type Limit struct {
+	Limit    int           `yaml:"limit"`
+	Duration time.Duration `yaml:"duration"`
+}
+
+type Key struct {
+	// Source of the client ID. Supported sources: query, header, cookie, ip, route, api-key, jwt, composite
+	Source string `yaml:"source"`
+
+	// Name of the query parameter, header or cookie containing the client ID.
+	// For the api-key and jwt sources, the header defaults to X-API-Key and Authorization.
+	Name string `yaml:"name"`
+
+	// Claim of the JWT containing the client ID
+	Claim string `yaml:"claim"`
+
+	// Secret is the HMAC secret verifying the JWT. If empty, the signature is not verified.
+	Secret string `yaml:"secret"`
+
+	// Parts of the composite key (e.g. client ID and route)
+	Parts []Key `yaml:"parts"`
+
+	// Fallback keys are tried in order if the client ID is missing
+	Fallback []Key `yaml:"fallback"`
+}
+
+type Route struct {
+	// Path is the route pattern (e.g. /users/:id)
+	Path string `yaml:"path"`
+
+	// Key overrides the client ID extraction for the route
+	Key Key `yaml:"key"`
+}
+
+type Rule struct {
+	// Name identifies the rule in the metrics and the traces. The counters are kept on reload if the name didn't change.
+	Name string `yaml:"name"`
+
+	// Methods are the HTTP methods matched by the rule. All methods are matched if empty.
+	Methods []string `yaml:"methods"`
+
+	// Path is a glob (e.g. /users/*/orders). A trailing /** matches the path and all the paths below it.
+	Path string `yaml:"path"`
+
+	// Headers must be present with the exact value. An empty value or * matches any value.
+	Headers map[string]string `yaml:"headers"`
+
+	// Tiers of the clients matched by the rule
+	Tiers []string `yaml:"tiers"`
+
+	// Sources are the IP CIDRs of the clients matched by the rule
+	Sources []string `yaml:"sources"`
+
+	// Limit is the maximum number of requests allowed within the duration of the window
+	Limit int `yaml:"limit"`
+
+	// Duration is the duration of the window
+	Duration time.Duration `yaml:"duration"`
+
+	// Shadow evaluates the rule without enforcing it
+	Shadow bool `yaml:"shadow"`
+
+	// Expressions compute the client ID, the cost and the limit of the requests matching the rule
+	Expressions Expressions `yaml:"expressions"`
+
+	// Schedules replace the limit of the rule during their periods. The first active period applies.
+	Schedules []Schedule `yaml:"schedules"`
+
+	// Priority is the priority class of the requests matching the rule. Optional.
+	Priority string `yaml:"priority"`
+}
+
+type Tier struct {
+	Name string `yaml:"name"`
+
+	// Clients is a list of client IDs, client ID prefixes (ending with *) and IP CIDRs in the tier
+	Clients []string `yaml:"clients"`
+}
+
+type Quota struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Path of the file the usage is persisted in
+	Path string `yaml:"path"`
+
+	// FlushInterval is the interval of persisting the usage. The usage is also persisted on shutdown.
+	FlushInterval time.Duration `yaml:"flushInterval"`
+
+	// MaxClients is the maximum number of tracked clients. When full, a new client replaces one of the least used clients.
+	MaxClients int `yaml:"maxClients"`
+
+	// Timezone of the calendar days and months (e.g. Europe/Ljubljana). Defaults to UTC.
+	Timezone string `yaml:"timezone"`
+
+	// Daily and Monthly are the budgets of the clients in the tiers without a plan. Zero is unlimited.
+	Daily   int `yaml:"daily"`
+	Monthly int `yaml:"monthly"`
+
+	// Plans are the budgets of the tiers
+	Plans []Plan `yaml:"plans"`
+}
+
+type Plan struct {
+	Tier    string `yaml:"tier"`
+	Daily   int    `yaml:"daily"`
+	Monthly int    `yaml:"monthly"`
+}
+
+// Shedding adapts a server-wide concurrency limit to the latency and the saturation of the server
+type Shedding struct {
+	Enabled bool `yaml:"enabled"`
+
+	// MinLimit and MaxLimit bound the concurrency limit
+	MinLimit int `yaml:"minLimit"`
+	MaxLimit int `yaml:"maxLimit"`
+
+	// InitialLimit is the concurrency limit before any latency is observed
+	InitialLimit int `yaml:"initialLimit"`
+
+	// TargetLatency is the handler latency above which the server is considered saturated
+	TargetLatency time.Duration `yaml:"targetLatency"`
+
+	// Backoff is the factor the limit is multiplied with when the server is saturated, between 0 and 1
+	Backoff float64 `yaml:"backoff"`
+
+	// MaxGoroutines is the number of goroutines above which the server is considered saturated. Zero disables the signal.
+	MaxGoroutines int `yaml:"maxGoroutines"`
+
+	// MaxSchedulingLatency is the 99th percentile of the goroutine scheduling latency above which the server is
+	// considered saturated. Zero disables the signal.
+	MaxSchedulingLatency time.Duration `yaml:"maxSchedulingLatency"`
+
+	// Reserved are the shares of the limit reserved for the higher priority classes
+	Reserved Reserved `yaml:"reserved"`
+}
+
+// Reserved shares of the limit can't be used by the lower priority classes. The low priority class can use the rest.
+type Reserved struct {
+	Critical float64 `yaml:"critical"`
+	High     float64 `yaml:"high"`
+	Normal   float64 `yaml:"normal"`
+}
+
+// Priority derives the priority classes of the requests, so the lower classes are shed first when the server is overloaded.
+// The header of the trusted clients takes precedence over the priority of the matched rule and the tier.
+type Priority struct {
+	// Default is the priority class of the requests without a priority. Supported classes: low, normal, high, critical
+	Default string `yaml:"default"`
+
+	// Header carries the priority class of the requests from the trusted clients (e.g. X-Priority). Optional.
+	Header string `yaml:"header"`
+
+	// Trusted is a list of client IDs, client ID prefixes (ending with *) and IP CIDRs allowed to set their priority with the header
+	Trusted []string `yaml:"trusted"`
+
+	// Tiers are the priority classes of the clients in the tiers
+	Tiers []TierPriority `yaml:"tiers"`
+}
+
+// Queue admits the requests to a bounded capacity. When the capacity is saturated, the requests wait in per-client
+// queues served with deficit round robin, so a client with many concurrent requests can't crowd out the other clients.
+type Queue struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Capacity is the number of requests evaluated at once
+	Capacity int `yaml:"capacity"`
+
+	// MaxLength is the maximum number of waiting requests of all clients
+	MaxLength int `yaml:"maxLength"`
+
+	// MaxWait is the maximum duration a request waits to be evaluated
+	MaxWait time.Duration `yaml:"maxWait"`
+
+	// Quantum is the cost a client can spend in every round
+	Quantum int `yaml:"quantum"`
+}
+
+type TierPriority struct {
+	Tier     string `yaml:"tier"`
+	Priority string `yaml:"priority"`
+}
+
+type Storage struct {
+	// Backend is the storage backend for the limiter state. Supported backends: memory
+	Backend string `yaml:"backend"`
+}
+
+type Logging struct {
+	// Level is the minimum log level. Supported levels: debug, info, warn, error
+	Level string `yaml:"level"`
+
+	// Format is the log format. Supported formats: json, console
+	Format string `yaml:"format"`
+}
+
+type Penalty struct {
+	Enabled      bool          `yaml:"enabled"`
+	Threshold    int           `yaml:"threshold"`
+	Windows      int           `yaml:"windows"`
+	BaseDuration time.Duration `yaml:"baseDuration"`
+	MaxDuration  time.Duration `yaml:"maxDuration"`
+}
+
+// Tarpit holds the responses to the limited and banned clients for a delay, which doubles with every offense.
+// The other requests of the recent offenders are held as well.
+type Tarpit struct {
+	Enabled   bool          `yaml:"enabled"`
+	BaseDelay time.Duration `yaml:"baseDelay"`
+	MaxDelay  time.Duration `yaml:"maxDelay"`
+
+	// MaxConnections is the maximum number of connections held at once. Responses are sent without a delay above it.
+	MaxConnections int `yaml:"maxConnections"`
+
+	// ForgetAfter is the duration after the last offense, after which the client's offenses are forgotten
+	ForgetAfter time.Duration `yaml:"forgetAfter"`
+}
+
+// Challenge issues proof-of-work challenges to the limited clients. A request with a solved challenge is admitted over the limit.
+type Challenge struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Secret signs the challenges. If empty, a random secret is generated on startup, so the challenges don't survive restarts.
+	Secret string `yaml:"secret"`
+
+	// Difficulty is the number of leading zero bits of the solution's hash, while the challenges are issued at the target rate
+	Difficulty int `yaml:"difficulty"`
+
+	// MaxDifficulty is the upper bound for the difficulty, which increases by a bit with every doubling of the rate
+	MaxDifficulty int `yaml:"maxDifficulty"`
+
+	// TargetRate is the number of challenges issued per second, above which the difficulty increases
+	TargetRate int `yaml:"targetRate"`
+
+	// TTL is the duration the challenge can be solved in
+	TTL time.Duration `yaml:"ttl"`
+}
+
+type Access struct {
+	// Allow is a list of client IDs, client ID prefixes (ending with *) and IP CIDRs that bypass the limiter
+	Allow []string `yaml:"allow"`
+
+	// Deny is a list of client IDs, client ID prefixes (ending with *) and IP CIDRs that are always denied
+	Deny []string `yaml:"deny"`
+}
+
+type Admin struct {
+	// Address is the address of the listener for the operational endpoints. Can be a TCP address or a unix socket (e.g. unix:/var/run/admin.sock).
+	// Defaults to the loopback interface, as the operational endpoints must not be reachable from the internet.
+	Address string `yaml:"address"`
+
+	// Profiling exposes the pprof endpoints
+	Profiling bool `yaml:"profiling"`
+
+	// Token is the bearer token required by the admin API. The admin API is disabled if the token is empty.
+	Token string `yaml:"token"`
+}
+
+type Tracing struct {
+	// Exporter of the spans. Supported exporters: none, otlp, stdout
+	Exporter string `yaml:"exporter"`
+
+	// Endpoint of the OTLP collector. If empty, the OTEL_EXPORTER_OTLP_ENDPOINT environment variable is used.
+	Endpoint string `yaml:"endpoint"`
+
+	// Insecure disables TLS for the OTLP exporter
+	Insecure bool `yaml:"insecure"`
+
+	// SampleRatio is the ratio of sampled traces, between 0 and 1
+	SampleRatio float64 `yaml:"sampleRatio"`
+
+	// ServiceName is the name of the service in the traces
+	ServiceName string `yaml:"serviceName"`
+}
+
+// Default returns the default configuration
+func Default() Config {
+	return Config{
+		Server: Server{
+			Address: ":80",
+		},
+		Limiter: Limiter{
+			Algorithm: "sliding-window",
+			Alignment: "first-request",
+			Limit:     200,
+			Duration:  time.Second * 5,
+			Sketch: Sketch{
+				Epsilon: 0.0001,
+				Delta:   0.01,
+			},
+		},
+		Key: Key{
+			Source: "query",
+			Name:   "clientId",
+		},
+		Storage: Storage{
+			Backend: "memory",
+		},
+		Quota: Quota{
+			Path:          "quotas.json",
+			FlushInterval: 30 * time.Second,
+			MaxClients:    100000,
+		},
+		Shedding: Shedding{
+			MinLimit:      10,
+			MaxLimit:      1000,
+			InitialLimit:  100,
+			TargetLatency: 50 * time.Millisecond,
+			Backoff:       0.9,
+			Reserved: Reserved{
+				Critical: 0.05,
+				High:     0.15,
+				Normal:   0.3,
+			},
+		},
+		Priority: Priority{
+			Default: "normal",
+		},
+		Queue: Queue{
+			Capacity:  100,
+			MaxLength: 1000,
+			MaxWait:   time.Second,
+			Quantum:   1,
+		},
+		Tarpit: Tarpit{
+			BaseDelay:      500 * time.Millisecond,
+			MaxDelay:       30 * time.Second,
+			MaxConnections: 100,
+			ForgetAfter:    5 * time.Minute,
+		},
+		Challenge: Challenge{
+			Difficulty:    16,
+			MaxDifficulty: 24,
+			TargetRate:    100,
+			TTL:           time.Minute,
+		},
+		Logging: Logging{
+			Level:  "info",
+			Format: "json",
+		},
+		Admin: Admin{
+			Address: "127.0.0.1:8081",
+		},
+		Tracing: Tracing{
+			Exporter:    "none",
+			SampleRatio: 1,
+			ServiceName: "rate-limiter",
+		},
+		Penalty: Penalty{
+			Enabled:      true,
+			Threshold:    10,
+			Windows:      3,
+			BaseDuration: time.Minute,
+			MaxDuration:  time.Hour,
+		},
+	}
+}
+
+// Override modifies the configuration after it was read from the file
+type Override func(*Config) error
+
+// Load reads the configuration file, applies the overrides and validates the configuration.
+// An empty path starts from the default configuration.
+func Load(path string, overrides ...Override) (Config, error) {
+	data := []byte{}
+	if path != "" {
+		var err error
+		data, err = os.ReadFile(path)
+		if err != nil {
+			return Config{}, errors.Wrap(err, "failed to read the configuration file")
+		}
+	}
+
+	return Parse(data, overrides...)
+}
+
+// Parse parses the configuration, applies the overrides and validates the configuration. Missing values are set to their defaults.
+func Parse(data []byte, overrides ...Override) (Config, error) {
+	cfg := Default()
+
+	// Keep the document to be able to report the line numbers of invalid values
+	document := &yaml.Node{}
+	if err := yaml.Unmarshal(data, document); err != nil {
+		return Config{}, err
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		return Config{}, err
+	}
+
+	for _, override := range overrides {
+		if err := override(&cfg); err != nil {
+			return Config{}, err
+		}
+	}
+
+	return cfg, validate(cfg, document)
+}
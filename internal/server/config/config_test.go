@@ -0,0 +1,405 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	data := []byte(`
+server:
+  address: ":8080"
+limiter:
+  limit: 5
+  duration: 10s
+  candidate:
+    limit: 3
+    duration: 10s
+access:
+  allow:
+    - internal-*
+    - 10.0.0.0/8
+`)
+
+	cfg, err := Parse(data)
+	assert.NoError(t, err)
+	assert.Equal(t, ":8080", cfg.Server.Address)
+	assert.Equal(t, 5, cfg.Limiter.Limit)
+	assert.Equal(t, 10*time.Second, cfg.Limiter.Duration)
+	assert.Equal(t, &Limit{Limit: 3, Duration: 10 * time.Second}, cfg.Limiter.Candidate)
+	assert.Equal(t, []string{"internal-*", "10.0.0.0/8"}, cfg.Access.Allow)
+
+	// Missing values are set to defaults
+	assert.Equal(t, "sliding-window", cfg.Limiter.Algorithm)
+	assert.Equal(t, "query", cfg.Key.Source)
+	assert.Equal(t, "clientId", cfg.Key.Name)
+	assert.Equal(t, "memory", cfg.Storage.Backend)
+}
+
+func TestParse_TrustedProxies(t *testing.T) {
+	cfg, err := Parse([]byte("server:\n  trustedProxies:\n    - 10.0.0.1\n    - 192.168.0.0/16\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.1", "192.168.0.0/16"}, cfg.Server.TrustedProxies)
+
+	_, err = Parse([]byte("server:\n  trustedProxies:\n    - proxy\n"))
+	assert.EqualError(t, err, "line 3: server.trustedProxies.0: must be an IP or a CIDR")
+}
+
+func TestParse_Empty(t *testing.T) {
+	cfg, err := Parse([]byte{})
+	assert.NoError(t, err)
+	assert.Equal(t, Default(), cfg)
+}
+
+func TestParse_UnknownField(t *testing.T) {
+	_, err := Parse([]byte("limiter:\n  limits: 5\n"))
+	assert.ErrorContains(t, err, "line 2")
+}
+
+func TestParse_ValidationErrors(t *testing.T) {
+	data := []byte(`
+limiter:
+  algorithm: leaky-bucket
+  limit: 0
+storage:
+  backend: redis
+access:
+  deny:
+    - 1
+    - ""
+`)
+
+	_, err := Parse(data)
+	validationErr := ValidationError{}
+	assert.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, ValidationError{
+		{Line: 3, Field: "limiter.algorithm", Message: "unsupported algorithm"},
+		{Line: 4, Field: "limiter.limit", Message: "must be greater than 0"},
+		{Line: 6, Field: "storage.backend", Message: "unsupported storage backend"},
+		{Line: 10, Field: "access.deny.1", Message: "invalid entry"},
+	}, validationErr)
+}
+
+func TestParse_Overrides(t *testing.T) {
+	env := map[string]string{
+		"RATE_LIMITER_LIMITER_LIMIT":    "50",
+		"RATE_LIMITER_LIMITER_DURATION": "1m",
+		"RATE_LIMITER_KEY_SOURCE":       "header",
+		"RATE_LIMITER_KEY_NAME":         "X-Client-ID",
+	}
+	lookup := func(name string) (string, bool) {
+		value, isSet := env[name]
+		return value, isSet
+	}
+
+	flags := func(cfg *Config) error {
+		cfg.Limiter.Limit = 100
+		return nil
+	}
+
+	cfg, err := Parse([]byte("limiter:\n  limit: 5\n"), fromLookup(lookup), flags)
+	assert.NoError(t, err)
+	assert.Equal(t, 100, cfg.Limiter.Limit)
+	assert.Equal(t, time.Minute, cfg.Limiter.Duration)
+	assert.Equal(t, "header", cfg.Key.Source)
+	assert.Equal(t, "X-Client-ID", cfg.Key.Name)
+
+	env["RATE_LIMITER_LIMITER_LIMIT"] = "many"
+	_, err = Parse([]byte{}, fromLookup(lookup))
+	assert.ErrorContains(t, err, "RATE_LIMITER_LIMITER_LIMIT")
+}
+
+func TestParse_CountMin(t *testing.T) {
+	cfg, err := Parse([]byte("limiter:\n  algorithm: count-min\n  sketch:\n    epsilon: 0.001\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, Sketch{Epsilon: 0.001, Delta: 0.01}, cfg.Limiter.Sketch)
+
+	_, err = Parse([]byte("limiter:\n  algorithm: count-min\n  sketch:\n    delta: 2\n"))
+	assert.EqualError(t, err, "line 4: limiter.sketch.delta: must be between 0 and 1")
+}
+
+func TestParse_Routes(t *testing.T) {
+	data := `
+key:
+  source: header
+  name: X-Client-ID
+  fallback:
+    - source: ip
+routes:
+  - path: /users/:id
+    key:
+      source: composite
+      parts:
+        - source: jwt
+          claim: sub
+        - source: route
+`
+	cfg, err := Parse([]byte(data))
+	assert.NoError(t, err)
+	assert.Equal(t, Key{Source: "header", Name: "X-Client-ID", Fallback: []Key{{Source: "ip"}}}, cfg.Key)
+	assert.Len(t, cfg.Routes, 1)
+	assert.Equal(t, "/users/:id", cfg.Routes[0].Path)
+	assert.Equal(t, []Key{{Source: "jwt", Claim: "sub"}, {Source: "route"}}, cfg.Routes[0].Key.Parts)
+
+	_, err = Parse([]byte("routes:\n  - path: /users\n    key:\n      source: composite\n      parts:\n        - source: jwt\n"))
+	assert.EqualError(t, err, "line 6: routes.0.key.parts.0.claim: must not be empty")
+
+	data = `
+routes:
+  - path: /users
+    key: {source: ip}
+  - path: /users
+    key: {source: ip}
+  - path: /usage
+    key: {source: ip}
+`
+	_, err = Parse([]byte(data))
+	assert.EqualError(t, err, strings.Join([]string{
+		"line 5: routes.1.path: duplicate route",
+		"line 7: routes.2.path: reserved route (/usage)",
+	}, "\n"))
+}
+
+func TestParse_Rules(t *testing.T) {
+	data := `
+tiers:
+  - name: premium
+    clients: ["premium-*"]
+rules:
+  - name: login
+    methods: [POST]
+    path: /login
+    limit: 5
+    duration: 1m
+  - name: search
+    path: /search/**
+    tiers: [premium]
+    sources: [10.0.0.0/8]
+    limit: 100
+    duration: 1m
+    shadow: true
+`
+	cfg, err := Parse([]byte(data))
+	assert.NoError(t, err)
+	assert.Len(t, cfg.Rules, 2)
+	assert.Equal(t, Rule{Name: "login", Methods: []string{"POST"}, Path: "/login", Limit: 5, Duration: time.Minute}, cfg.Rules[0])
+	assert.Equal(t, []Tier{{Name: "premium", Clients: []string{"premium-*"}}}, cfg.Tiers)
+
+	data = `
+rules:
+  - name: search
+    methods: [FETCH]
+    path: /search/[
+    tiers: [premium]
+    sources: [10.0.0.0]
+    limit: 0
+    duration: 1m
+`
+	_, err = Parse([]byte(data))
+	assert.EqualError(t, err, strings.Join([]string{
+		"line 4: rules.0.methods.0: unsupported method",
+		"line 5: rules.0.path: invalid glob",
+		"line 6: rules.0.tiers.0: unknown tier",
+		"line 7: rules.0.sources.0: invalid CIDR",
+		"line 8: rules.0.limit: must be greater than 0",
+	}, "\n"))
+
+	// The names of the global and the candidate limiters are reserved
+	_, err = Parse([]byte("rules:\n  - name: candidate\n    limit: 5\n    duration: 1m\n    shadow: true\n"))
+	assert.EqualError(t, err, "line 2: rules.0.name: must not be empty or reserved (default, candidate)")
+}
+
+func TestParse_Expressions(t *testing.T) {
+	data := `
+limiter:
+  expressions:
+    cost: 'method == "POST" && body_size > 1048576 ? 5 : 1'
+rules:
+  - name: search
+    path: /search
+    limit: 100
+    duration: 1m
+    expressions:
+      key: client_id
+      limit: '"100"'
+`
+	_, err := Parse([]byte(data))
+	assert.EqualError(t, err, "line 12: rules.0.expressions.limit: limit expression must return int, not string")
+}
+
+func TestParse_Schedules(t *testing.T) {
+	data := `
+limiter:
+  schedules:
+    - name: business-hours
+      days: [mon, tue, wed, thu, fri]
+      from: "09:00"
+      to: "17:00"
+      timezone: Europe/Ljubljana
+      limit: 100
+    - name: maintenance
+      cron: 0 2 * * SUN
+      for: 2h
+      limit: 10
+`
+	cfg, err := Parse([]byte(data))
+	assert.NoError(t, err)
+	assert.Len(t, cfg.Limiter.Schedules, 2)
+	assert.Equal(t, 2*time.Hour, cfg.Limiter.Schedules[1].For)
+
+	data = `
+limiter:
+  schedules:
+    - name: night
+      days: [someday]
+      from: "22:00"
+      to: "6:00"
+      timezone: Mars/Olympus
+      limit: 100
+`
+	_, err = Parse([]byte(data))
+	assert.EqualError(t, err, strings.Join([]string{
+		"line 7: limiter.schedules.0.to: must be a time of day (HH:MM)",
+		"line 5: limiter.schedules.0.days.0: invalid day of the week",
+		"line 8: limiter.schedules.0.timezone: unknown time zone",
+	}, "\n"))
+
+	_, err = Parse([]byte("limiter:\n  schedules:\n    - name: never\n      from: \"08:00\"\n      to: \"08:00\"\n      limit: 100\n"))
+	assert.EqualError(t, err, "line 5: limiter.schedules.0.to: must differ from the start of the time range")
+}
+
+func TestParse_Quota(t *testing.T) {
+	data := `
+tiers:
+  - name: premium
+    clients: ["premium-*"]
+quota:
+  enabled: true
+  monthly: 1000
+  plans:
+    - tier: premium
+      monthly: 100000
+    - tier: enterprise
+      daily: -1
+`
+	_, err := Parse([]byte(data))
+	assert.EqualError(t, err, strings.Join([]string{
+		"line 11: quota.plans.1.tier: unknown tier",
+		"line 12: quota.plans.1.daily: must not be negative",
+	}, "\n"))
+}
+
+func TestParse_Alignment(t *testing.T) {
+	cfg, err := Parse([]byte("limiter:\n  alignment: clock\n  duration: 1m\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, "clock", cfg.Limiter.Alignment)
+
+	_, err = Parse([]byte("limiter:\n  alignment: calendar\n"))
+	assert.EqualError(t, err, "line 2: limiter.alignment: unsupported alignment")
+}
+
+func TestParse_Concurrency(t *testing.T) {
+	cfg, err := Parse([]byte("limiter:\n  concurrency: 5\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, 5, cfg.Limiter.Concurrency)
+
+	_, err = Parse([]byte("limiter:\n  concurrency: -1\n"))
+	assert.EqualError(t, err, "line 2: limiter.concurrency: must not be negative")
+}
+
+func TestParse_Shedding(t *testing.T) {
+	cfg, err := Parse([]byte("shedding:\n  enabled: true\n  maxGoroutines: 10000\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, Shedding{
+		Enabled:       true,
+		MinLimit:      10,
+		MaxLimit:      1000,
+		InitialLimit:  100,
+		TargetLatency: 50 * time.Millisecond,
+		Backoff:       0.9,
+		MaxGoroutines: 10000,
+		Reserved:      Reserved{Critical: 0.05, High: 0.15, Normal: 0.3},
+	}, cfg.Shedding)
+
+	_, err = Parse([]byte("shedding:\n  enabled: true\n  initialLimit: 5\n  backoff: 1.5\n"))
+	assert.EqualError(t, err, strings.Join([]string{
+		"line 3: shedding.initialLimit: must be between the minimum and the maximum limit",
+		"line 4: shedding.backoff: must be between 0 and 1",
+	}, "\n"))
+}
+
+func TestParse_Priority(t *testing.T) {
+	data := `
+tiers:
+  - name: premium
+    clients: ["premium-*"]
+priority:
+  header: X-Priority
+  trusted: ["internal-*"]
+  tiers:
+    - tier: premium
+      priority: high
+rules:
+  - name: health
+    path: /health
+    limit: 100
+    duration: 1s
+    priority: critical
+`
+	cfg, err := Parse([]byte(data))
+	assert.NoError(t, err)
+	assert.Equal(t, "normal", cfg.Priority.Default)
+	assert.Equal(t, []TierPriority{{Tier: "premium", Priority: "high"}}, cfg.Priority.Tiers)
+	assert.Equal(t, "critical", cfg.Rules[0].Priority)
+
+	data = `
+priority:
+  default: urgent
+  tiers:
+    - tier: enterprise
+      priority: high
+shedding:
+  enabled: true
+  reserved:
+    critical: 0.5
+    high: 0.5
+`
+	_, err = Parse([]byte(data))
+	assert.EqualError(t, err, strings.Join([]string{
+		"line 10: shedding.reserved: must leave part of the limit to the low priority class",
+		"line 3: priority.default: unsupported priority class",
+		"line 5: priority.tiers.0.tier: unknown tier",
+	}, "\n"))
+}
+
+func TestParse_Queue(t *testing.T) {
+	cfg, err := Parse([]byte("queue:\n  enabled: true\n  capacity: 10\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, Queue{Enabled: true, Capacity: 10, MaxLength: 1000, MaxWait: time.Second, Quantum: 1}, cfg.Queue)
+
+	_, err = Parse([]byte("queue:\n  enabled: true\n  maxWait: 0s\n"))
+	assert.EqualError(t, err, "line 3: queue.maxWait: must be positive")
+}
+
+func TestParse_Tarpit(t *testing.T) {
+	cfg, err := Parse([]byte("tarpit:\n  enabled: true\n  maxConnections: 10\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, Tarpit{Enabled: true, BaseDelay: 500 * time.Millisecond, MaxDelay: 30 * time.Second, MaxConnections: 10, ForgetAfter: 5 * time.Minute}, cfg.Tarpit)
+
+	_, err = Parse([]byte("tarpit:\n  enabled: true\n  maxDelay: 100ms\n"))
+	assert.EqualError(t, err, "line 3: tarpit.maxDelay: must not be shorter than the base delay")
+}
+
+func TestParse_Challenge(t *testing.T) {
+	cfg, err := Parse([]byte("challenge:\n  enabled: true\n  difficulty: 20\n"), fromLookup(func(name string) (string, bool) {
+		return "secret", name == "RATE_LIMITER_CHALLENGE_SECRET"
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, Challenge{Enabled: true, Secret: "secret", Difficulty: 20, MaxDifficulty: 24, TargetRate: 100, TTL: time.Minute}, cfg.Challenge)
+
+	_, err = Parse([]byte("challenge:\n  enabled: true\n  maxDifficulty: 8\n"))
+	assert.EqualError(t, err, "line 3: challenge.maxDifficulty: must be between the difficulty and 64")
+}
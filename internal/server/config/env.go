@@ -0,0 +1,106 @@
+package config
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// EnvPrefix is the prefix of all environment variables overriding the configuration
+const EnvPrefix = "RATE_LIMITER_"
+
+// envVars maps the environment variables (without the prefix) to the configuration fields they override.
+var envVars = map[string]func(cfg *Config, value string) error{
+	"SERVER_ADDRESS": func(cfg *Config, value string) error {
+		cfg.Server.Address = value
+		return nil
+	},
+	"LIMITER_ALGORITHM": func(cfg *Config, value string) error {
+		cfg.Limiter.Algorithm = value
+		return nil
+	},
+	"LIMITER_LIMIT": func(cfg *Config, value string) (err error) {
+		cfg.Limiter.Limit, err = strconv.Atoi(value)
+		return err
+	},
+	"LIMITER_DURATION": func(cfg *Config, value string) (err error) {
+		cfg.Limiter.Duration, err = time.ParseDuration(value)
+		return err
+	},
+	"LIMITER_CONCURRENCY": func(cfg *Config, value string) (err error) {
+		cfg.Limiter.Concurrency, err = strconv.Atoi(value)
+		return err
+	},
+	"LIMITER_ALIGNMENT": func(cfg *Config, value string) error {
+		cfg.Limiter.Alignment = value
+		return nil
+	},
+	"LIMITER_SHADOW": func(cfg *Config, value string) (err error) {
+		cfg.Limiter.Shadow, err = strconv.ParseBool(value)
+		return err
+	},
+	"KEY_SOURCE": func(cfg *Config, value string) error {
+		cfg.Key.Source = value
+		return nil
+	},
+	"KEY_NAME": func(cfg *Config, value string) error {
+		cfg.Key.Name = value
+		return nil
+	},
+	"STORAGE_BACKEND": func(cfg *Config, value string) error {
+		cfg.Storage.Backend = value
+		return nil
+	},
+	"LOGGING_LEVEL": func(cfg *Config, value string) error {
+		cfg.Logging.Level = value
+		return nil
+	},
+	"LOGGING_FORMAT": func(cfg *Config, value string) error {
+		cfg.Logging.Format = value
+		return nil
+	},
+	"TRACING_EXPORTER": func(cfg *Config, value string) error {
+		cfg.Tracing.Exporter = value
+		return nil
+	},
+	"TRACING_ENDPOINT": func(cfg *Config, value string) error {
+		cfg.Tracing.Endpoint = value
+		return nil
+	},
+	"ADMIN_ADDRESS": func(cfg *Config, value string) error {
+		cfg.Admin.Address = value
+		return nil
+	},
+	"ADMIN_TOKEN": func(cfg *Config, value string) error {
+		cfg.Admin.Token = value
+		return nil
+	},
+	"CHALLENGE_SECRET": func(cfg *Config, value string) error {
+		cfg.Challenge.Secret = value
+		return nil
+	},
+}
+
+// FromEnv overrides the configuration with the environment variables (e.g. RATE_LIMITER_LIMITER_LIMIT=100).
+func FromEnv() Override {
+	return fromLookup(os.LookupEnv)
+}
+
+func fromLookup(lookup func(string) (string, bool)) Override {
+	return func(cfg *Config) error {
+		for name, set := range envVars {
+			value, isSet := lookup(EnvPrefix + name)
+			if !isSet {
+				continue
+			}
+
+			if err := set(cfg, value); err != nil {
+				return errors.Wrapf(err, "invalid value of %s%s", EnvPrefix, name)
+			}
+		}
+
+		return nil
+	}
+}
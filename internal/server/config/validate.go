@@ -0,0 +1,406 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"net/netip"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/access"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/expressions"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/rate-limiter"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/schedule"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/shedding"
+	"gopkg.in/yaml.v3"
+)
+
+const minDuration = 100 * time.Millisecond
+
+// reservedRules are the names of the global limiter and the candidate limiter, which share the metrics and the shadow
+// stats with the rules
+var reservedRules = []string{"default", "candidate"}
+
+// reservedRoutes are served by the server itself
+var reservedRoutes = []string{"/usage"}
+
+var httpMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+	http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace,
+}
+
+// FieldError is a validation error of a single configuration field
+type FieldError struct {
+	// Line in the configuration file. Zero if the value was not set in the file.
+	Line int
+
+	// Field is the path to the field (e.g. limiter.limit)
+	Field string
+
+	Message string
+}
+
+func (e FieldError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("line %d: %s: %s", e.Line, e.Field, e.Message)
+	}
+
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// ValidationError contains all the field errors of the configuration
+type ValidationError []FieldError
+
+func (e ValidationError) Error() string {
+	messages := []string{}
+	for _, fieldError := range e {
+		messages = append(messages, fieldError.Error())
+	}
+
+	return strings.Join(messages, "\n")
+}
+
+// Validate validates the configuration
+func Validate(cfg Config) error {
+	return validate(cfg, nil)
+}
+
+type validator struct {
+	document *yaml.Node
+	errors   ValidationError
+}
+
+func (v *validator) check(ok bool, field, message string) {
+	if ok {
+		return
+	}
+
+	v.errors = append(v.errors, FieldError{
+		Line:    lineOf(v.document, field),
+		Field:   field,
+		Message: message,
+	})
+}
+
+func validate(cfg Config, document *yaml.Node) error {
+	v := &validator{document: document}
+
+	v.check(cfg.Server.Address != "", "server.address", "must not be empty")
+	for i, proxy := range cfg.Server.TrustedProxies {
+		_, prefixErr := netip.ParsePrefix(proxy)
+		_, addrErr := netip.ParseAddr(proxy)
+		v.check(prefixErr == nil || addrErr == nil, fmt.Sprintf("server.trustedProxies.%d", i), "must be an IP or a CIDR")
+	}
+	v.check(cfg.Admin.Address != "", "admin.address", "must not be empty")
+	v.check(cfg.Admin.Address != cfg.Server.Address, "admin.address", "must differ from the server address")
+
+	v.check(oneOf(cfg.Limiter.Algorithm, "sliding-window", "count-min"), "limiter.algorithm", "unsupported algorithm")
+	if cfg.Limiter.Algorithm == "count-min" {
+		v.check(cfg.Limiter.Sketch.Epsilon > 0 && cfg.Limiter.Sketch.Epsilon < 1, "limiter.sketch.epsilon", "must be between 0 and 1")
+		v.check(cfg.Limiter.Sketch.Delta > 0 && cfg.Limiter.Sketch.Delta < 1, "limiter.sketch.delta", "must be between 0 and 1")
+	}
+	v.check(oneOf(cfg.Limiter.Alignment, "first-request", "clock"), "limiter.alignment", "unsupported alignment")
+	v.check(cfg.Limiter.Limit > 0, "limiter.limit", "must be greater than 0")
+	v.check(cfg.Limiter.Duration >= minDuration, "limiter.duration", "must be at least 100ms")
+	v.check(cfg.Limiter.Concurrency >= 0, "limiter.concurrency", "must not be negative")
+	if cfg.Limiter.Candidate != nil {
+		v.check(cfg.Limiter.Candidate.Limit > 0, "limiter.candidate.limit", "must be greater than 0")
+		v.check(cfg.Limiter.Candidate.Duration >= minDuration, "limiter.candidate.duration", "must be at least 100ms")
+	}
+
+	v.checkExpressions(cfg.Limiter.Expressions, "limiter.expressions")
+	v.checkSchedules(cfg.Limiter.Schedules, cfg.Limiter.Algorithm, "limiter.schedules")
+
+	v.checkKey(cfg.Key, "key")
+
+	routes := map[string]bool{}
+	for i, route := range cfg.Routes {
+		field := fmt.Sprintf("routes.%d", i)
+		v.check(strings.HasPrefix(route.Path, "/"), field+".path", "must start with /")
+		v.check(!routes[route.Path], field+".path", "duplicate route")
+		v.check(!oneOf(route.Path, reservedRoutes...), field+".path", "reserved route (/usage)")
+		routes[route.Path] = true
+		v.checkKey(route.Key, field+".key")
+	}
+
+	tiers := map[string]bool{}
+	for i, tier := range cfg.Tiers {
+		field := fmt.Sprintf("tiers.%d", i)
+		v.check(tier.Name != "", field+".name", "must not be empty")
+		v.check(!tiers[tier.Name], field+".name", "duplicate tier")
+		tiers[tier.Name] = true
+
+		_, err := access.NewList(tier.Clients...)
+		v.check(err == nil, field+".clients", "invalid entry")
+	}
+
+	rules := map[string]bool{}
+	for i, rule := range cfg.Rules {
+		field := fmt.Sprintf("rules.%d", i)
+		v.check(rule.Name != "" && !oneOf(rule.Name, reservedRules...), field+".name", "must not be empty or reserved (default, candidate)")
+		v.check(!rules[rule.Name], field+".name", "duplicate rule")
+		rules[rule.Name] = true
+
+		for j, method := range rule.Methods {
+			v.check(oneOf(strings.ToUpper(method), httpMethods...), fmt.Sprintf("%s.methods.%d", field, j), "unsupported method")
+		}
+
+		_, err := path.Match(strings.TrimSuffix(rule.Path, "/**"), "")
+		v.check(err == nil, field+".path", "invalid glob")
+
+		for j, tier := range rule.Tiers {
+			v.check(tier == "default" || tiers[tier], fmt.Sprintf("%s.tiers.%d", field, j), "unknown tier")
+		}
+
+		for j, source := range rule.Sources {
+			_, err := netip.ParsePrefix(source)
+			v.check(err == nil, fmt.Sprintf("%s.sources.%d", field, j), "invalid CIDR")
+		}
+
+		v.check(rule.Limit > 0, field+".limit", "must be greater than 0")
+		v.check(rule.Duration >= minDuration, field+".duration", "must be at least 100ms")
+		v.checkExpressions(rule.Expressions, field+".expressions")
+		v.checkSchedules(rule.Schedules, cfg.Limiter.Algorithm, field+".schedules")
+		if rule.Priority != "" {
+			v.checkPriority(rule.Priority, field+".priority")
+		}
+	}
+
+	if cfg.Quota.Enabled {
+		v.check(cfg.Quota.Path != "", "quota.path", "must not be empty")
+		v.check(cfg.Quota.FlushInterval >= minDuration, "quota.flushInterval", "must be at least 100ms")
+		v.check(cfg.Quota.MaxClients > 0, "quota.maxClients", "must be positive")
+		_, err := time.LoadLocation(cfg.Quota.Timezone)
+		v.check(err == nil, "quota.timezone", "unknown time zone")
+		v.check(cfg.Quota.Daily >= 0, "quota.daily", "must not be negative")
+		v.check(cfg.Quota.Monthly >= 0, "quota.monthly", "must not be negative")
+
+		plans := map[string]bool{}
+		for i, plan := range cfg.Quota.Plans {
+			field := fmt.Sprintf("quota.plans.%d", i)
+			v.check(plan.Tier == "default" || tiers[plan.Tier], field+".tier", "unknown tier")
+			v.check(!plans[plan.Tier], field+".tier", "duplicate plan")
+			plans[plan.Tier] = true
+			v.check(plan.Daily >= 0, field+".daily", "must not be negative")
+			v.check(plan.Monthly >= 0, field+".monthly", "must not be negative")
+		}
+	}
+
+	if cfg.Shedding.Enabled {
+		v.check(cfg.Shedding.MinLimit > 0, "shedding.minLimit", "must be greater than 0")
+		v.check(cfg.Shedding.MaxLimit >= cfg.Shedding.MinLimit, "shedding.maxLimit", "must not be lower than the minimum limit")
+		v.check(cfg.Shedding.InitialLimit >= cfg.Shedding.MinLimit && cfg.Shedding.InitialLimit <= cfg.Shedding.MaxLimit, "shedding.initialLimit", "must be between the minimum and the maximum limit")
+		v.check(cfg.Shedding.TargetLatency > 0, "shedding.targetLatency", "must be positive")
+		v.check(cfg.Shedding.Backoff > 0 && cfg.Shedding.Backoff < 1, "shedding.backoff", "must be between 0 and 1")
+		v.check(cfg.Shedding.MaxGoroutines >= 0, "shedding.maxGoroutines", "must not be negative")
+		v.check(cfg.Shedding.MaxSchedulingLatency >= 0, "shedding.maxSchedulingLatency", "must not be negative")
+
+		reserved := cfg.Shedding.Reserved
+		v.check(reserved.Critical >= 0, "shedding.reserved.critical", "must not be negative")
+		v.check(reserved.High >= 0, "shedding.reserved.high", "must not be negative")
+		v.check(reserved.Normal >= 0, "shedding.reserved.normal", "must not be negative")
+		v.check(reserved.Critical+reserved.High+reserved.Normal < 1, "shedding.reserved", "must leave part of the limit to the low priority class")
+	}
+
+	if cfg.Queue.Enabled {
+		v.check(cfg.Queue.Capacity > 0, "queue.capacity", "must be greater than 0")
+		v.check(cfg.Queue.MaxLength >= 0, "queue.maxLength", "must not be negative")
+		v.check(cfg.Queue.MaxWait > 0, "queue.maxWait", "must be positive")
+		v.check(cfg.Queue.Quantum > 0, "queue.quantum", "must be greater than 0")
+	}
+
+	v.checkPriority(cfg.Priority.Default, "priority.default")
+	for i, entry := range cfg.Priority.Trusted {
+		_, err := access.NewList(entry)
+		v.check(err == nil, fmt.Sprintf("priority.trusted.%d", i), "invalid entry")
+	}
+
+	for i, tierPriority := range cfg.Priority.Tiers {
+		field := fmt.Sprintf("priority.tiers.%d", i)
+		v.check(tierPriority.Tier == "default" || tiers[tierPriority.Tier], field+".tier", "unknown tier")
+		v.checkPriority(tierPriority.Priority, field+".priority")
+	}
+
+	v.check(oneOf(cfg.Storage.Backend, "memory"), "storage.backend", "unsupported storage backend")
+
+	v.check(oneOf(cfg.Logging.Level, "debug", "info", "warn", "error"), "logging.level", "unsupported log level")
+	v.check(oneOf(cfg.Logging.Format, "json", "console"), "logging.format", "unsupported log format")
+
+	v.check(oneOf(cfg.Tracing.Exporter, "none", "otlp", "stdout"), "tracing.exporter", "unsupported exporter")
+	v.check(cfg.Tracing.SampleRatio >= 0 && cfg.Tracing.SampleRatio <= 1, "tracing.sampleRatio", "must be between 0 and 1")
+
+	if cfg.Penalty.Enabled {
+		v.check(cfg.Penalty.Threshold > 0, "penalty.threshold", "must be greater than 0")
+		v.check(cfg.Penalty.Windows > 0, "penalty.windows", "must be greater than 0")
+		v.check(cfg.Penalty.BaseDuration > 0, "penalty.baseDuration", "must be positive")
+		v.check(cfg.Penalty.MaxDuration >= cfg.Penalty.BaseDuration, "penalty.maxDuration", "must not be shorter than the base duration")
+	}
+
+	if cfg.Tarpit.Enabled {
+		v.check(cfg.Tarpit.BaseDelay > 0, "tarpit.baseDelay", "must be positive")
+		v.check(cfg.Tarpit.MaxDelay >= cfg.Tarpit.BaseDelay, "tarpit.maxDelay", "must not be shorter than the base delay")
+		v.check(cfg.Tarpit.MaxConnections > 0, "tarpit.maxConnections", "must be greater than 0")
+		v.check(cfg.Tarpit.ForgetAfter > 0, "tarpit.forgetAfter", "must be positive")
+	}
+
+	if cfg.Challenge.Enabled {
+		v.check(cfg.Challenge.Difficulty > 0, "challenge.difficulty", "must be greater than 0")
+		v.check(cfg.Challenge.MaxDifficulty >= cfg.Challenge.Difficulty && cfg.Challenge.MaxDifficulty <= 64, "challenge.maxDifficulty", "must be between the difficulty and 64")
+		v.check(cfg.Challenge.TargetRate > 0, "challenge.targetRate", "must be greater than 0")
+		v.check(cfg.Challenge.TTL >= time.Second, "challenge.ttl", "must be at least 1s")
+	}
+
+	for i, entry := range cfg.Access.Allow {
+		_, err := access.NewList(entry)
+		v.check(err == nil, fmt.Sprintf("access.allow.%d", i), "invalid entry")
+	}
+
+	for i, entry := range cfg.Access.Deny {
+		_, err := access.NewList(entry)
+		v.check(err == nil, fmt.Sprintf("access.deny.%d", i), "invalid entry")
+	}
+
+	if len(v.errors) > 0 {
+		return v.errors
+	}
+
+	return nil
+}
+
+func (v *validator) checkKey(key Key, field string) {
+	v.check(oneOf(key.Source, "query", "header", "cookie", "ip", "route", "api-key", "jwt", "composite"), field+".source", "unsupported key source")
+
+	switch key.Source {
+	case "query", "header", "cookie":
+		v.check(key.Name != "", field+".name", "must not be empty")
+	case "jwt":
+		v.check(key.Claim != "", field+".claim", "must not be empty")
+	case "composite":
+		v.check(len(key.Parts) > 0, field+".parts", "must not be empty")
+	}
+
+	for i, part := range key.Parts {
+		v.checkKey(part, fmt.Sprintf("%s.parts.%d", field, i))
+	}
+
+	for i, fallback := range key.Fallback {
+		v.checkKey(fallback, fmt.Sprintf("%s.fallback.%d", field, i))
+	}
+}
+
+func (v *validator) checkExpressions(e Expressions, field string) {
+	sources := []struct {
+		name   string
+		source expressions.Source
+	}{
+		{name: "key", source: expressions.Source{Key: e.Key}},
+		{name: "cost", source: expressions.Source{Cost: e.Cost}},
+		{name: "limit", source: expressions.Source{Limit: e.Limit}},
+	}
+
+	for _, s := range sources {
+		_, err := expressions.Compile(s.source)
+		v.check(err == nil, field+"."+s.name, fmt.Sprint(err))
+	}
+}
+
+func (v *validator) checkPriority(priority, field string) {
+	_, ok := shedding.ParsePriority(priority)
+	v.check(ok, field, "unsupported priority class")
+}
+
+func (v *validator) checkSchedules(schedules []Schedule, algorithm, field string) {
+	if len(schedules) > 0 {
+		v.check(algorithm == "sliding-window", field, "only supported by the sliding-window algorithm")
+	}
+
+	names := map[string]bool{}
+	for i, s := range schedules {
+		field := fmt.Sprintf("%s.%d", field, i)
+		v.check(s.Name != "", field+".name", "must not be empty")
+		v.check(!names[s.Name], field+".name", "duplicate schedule")
+		names[s.Name] = true
+
+		if s.Cron != "" {
+			v.check(s.From == "" && s.To == "" && len(s.Days) == 0, field+".cron", "must not be combined with a time range")
+			_, err := schedule.NewCron(s.Name, rate_limiter.Config{}, s.Cron, time.Hour, time.UTC)
+			v.check(err == nil, field+".cron", "invalid cron expression")
+			v.check(s.For > 0, field+".for", "must be positive")
+		} else {
+			from, fromErr := schedule.ParseTimeOfDay(s.From)
+			v.check(fromErr == nil, field+".from", "must be a time of day (HH:MM)")
+			to, toErr := schedule.ParseTimeOfDay(s.To)
+			v.check(toErr == nil, field+".to", "must be a time of day (HH:MM)")
+
+			// An empty time range is never active
+			if fromErr == nil && toErr == nil {
+				v.check(from != to, field+".to", "must differ from the start of the time range")
+			}
+		}
+
+		for j, day := range s.Days {
+			_, err := schedule.ParseWeekday(day)
+			v.check(err == nil, fmt.Sprintf("%s.days.%d", field, j), "invalid day of the week")
+		}
+
+		_, err := time.LoadLocation(s.Timezone)
+		v.check(err == nil, field+".timezone", "unknown time zone")
+		v.check(s.Limit > 0, field+".limit", "must be greater than 0")
+		v.check(s.Duration == 0 || s.Duration >= minDuration, field+".duration", "must be at least 100ms")
+	}
+}
+
+func oneOf(value string, allowed ...string) bool {
+	for _, a := range allowed {
+		if value == a {
+			return true
+		}
+	}
+
+	return false
+}
+
+// lineOf finds the line of the field in the document. The field is a dot separated path, where sequence items are referenced by their index.
+// If the field is not in the document, the line of the closest parent is returned.
+func lineOf(document *yaml.Node, field string) int {
+	if document == nil {
+		return 0
+	}
+
+	node := document
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+
+	line := 0
+	for _, key := range strings.Split(field, ".") {
+		next := childOf(node, key)
+		if next == nil {
+			return line
+		}
+
+		node = next
+		line = node.Line
+	}
+
+	return line
+}
+
+func childOf(node *yaml.Node, key string) *yaml.Node {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				return node.Content[i+1]
+			}
+		}
+	case yaml.SequenceNode:
+		var index int
+		if _, err := fmt.Sscanf(key, "%d", &index); err == nil && index >= 0 && index < len(node.Content) {
+			return node.Content[index]
+		}
+	}
+
+	return nil
+}
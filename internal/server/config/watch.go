@@ -0,0 +1,78 @@
+package config
+
+import (
+	"context"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// debounceInterval is the time to wait for the file changes to settle, as editors usually write the file in multiple steps.
+const debounceInterval = 500 * time.Millisecond
+
+// Watch calls onChange when the configuration file changes, until the context is cancelled.
+// The directory of the file is watched, so the changes are detected even if the file is replaced. If the file is
+// a symlink, the changes of its target are detected as well (e.g. a Kubernetes ConfigMap swapping its ..data symlink).
+func Watch(ctx context.Context, path string, onChange func()) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return errors.Wrap(err, "failed to create a file watcher")
+	}
+
+	path = filepath.Clean(path)
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		_ = watcher.Close()
+		return errors.Wrap(err, "failed to watch the configuration file")
+	}
+
+	logger := zap.L().Named("config-watcher")
+	target, _ := filepath.EvalSymlinks(path)
+
+	go func() {
+		defer watcher.Close()
+
+		debounce := time.NewTimer(debounceInterval)
+		debounce.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+
+				if !event.Has(fsnotify.Write | fsnotify.Create | fsnotify.Rename) {
+					continue
+				}
+
+				// The symlinks of the file can change without an event for the file itself
+				if filepath.Clean(event.Name) != path {
+					resolved, err := filepath.EvalSymlinks(path)
+					if err != nil || resolved == target {
+						continue
+					}
+
+					target = resolved
+				}
+
+				debounce.Reset(debounceInterval)
+			case <-debounce.C:
+				logger.Info("Configuration file changed", zap.String("path", path))
+				onChange()
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+
+				logger.Warn("Failed to watch the configuration file", zap.Error(err))
+			}
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestWatch(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	zap.ReplaceGlobals(logger)
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(path, []byte("limiter:\n  limit: 5\n"), 0o600)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	changed := make(chan struct{}, 1)
+	err = Watch(ctx, path, func() {
+		changed <- struct{}{}
+	})
+	assert.NoError(t, err)
+
+	// Changes of other files are ignored
+	err = os.WriteFile(filepath.Join(filepath.Dir(path), "other.yaml"), []byte{}, 0o600)
+	assert.NoError(t, err)
+
+	err = os.WriteFile(path, []byte("limiter:\n  limit: 10\n"), 0o600)
+	assert.NoError(t, err)
+
+	select {
+	case <-changed:
+	case <-ctx.Done():
+		t.Fatal("change was not detected")
+	}
+
+	select {
+	case <-changed:
+		t.Fatal("change was reported more than once")
+	case <-time.After(2 * debounceInterval):
+	}
+}
+
+func TestWatch_Symlink(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	zap.ReplaceGlobals(logger)
+
+	// The layout of a mounted Kubernetes ConfigMap
+	dir := t.TempDir()
+	writeVersion := func(version, data string) {
+		assert.NoError(t, os.Mkdir(filepath.Join(dir, version), 0o700))
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, version, "config.yaml"), []byte(data), 0o600))
+	}
+
+	writeVersion("..v1", "limiter:\n  limit: 5\n")
+	assert.NoError(t, os.Symlink("..v1", filepath.Join(dir, "..data")))
+	path := filepath.Join(dir, "config.yaml")
+	assert.NoError(t, os.Symlink(filepath.Join("..data", "config.yaml"), path))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	changed := make(chan struct{}, 1)
+	err := Watch(ctx, path, func() {
+		changed <- struct{}{}
+	})
+	assert.NoError(t, err)
+
+	// The ConfigMap is updated by atomically swapping the ..data symlink
+	writeVersion("..v2", "limiter:\n  limit: 10\n")
+	assert.NoError(t, os.Symlink("..v2", filepath.Join(dir, "..data_tmp")))
+	assert.NoError(t, os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")))
+
+	select {
+	case <-changed:
+	case <-ctx.Done():
+		t.Fatal("change was not detected")
+	}
+}
@@ -0,0 +1,209 @@
+package expressions
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/pkg/errors"
+)
+
+// Source is the CEL source of the expressions. Empty expressions are not evaluated.
+//
+// The expressions can use the following variables:
+//   - method, path and route (the route pattern, e.g. /users/:id) of the request,
+//   - headers and query, maps of the first values by the lowercase header names and the query parameters,
+//   - client_id, ip and tier of the client, and the rule matching the request,
+//   - body_size, the content length of the request or -1 if unknown (e.g. the chunked bodies). The costs based on the size
+//     must charge the unknown size too (e.g. body_size < 0 || body_size > 1048576 ? 5 : 1), or the clients can avoid
+//     them by sending the body chunked,
+//   - now, the time of the request (e.g. now.getHours("Europe/Ljubljana")).
+//
+// The evaluation of an expression is aborted once its cost exceeds the CostLimit.
+type Source struct {
+	// Key computes the client ID (string)
+	Key string
+
+	// Cost computes the units charged for the request (int)
+	Cost string
+
+	// Limit computes the limit the request is evaluated against (int)
+	Limit string
+}
+
+// CostLimit is the maximum cost of evaluating an expression, which bounds the time spent on the expressions
+// with large inputs (e.g. long header values).
+const CostLimit = 100000
+
+// Request is the input of the expressions
+type Request struct {
+	Method   string
+	Path     string
+	Route    string
+	Header   http.Header
+	Query    url.Values
+	ClientID string
+	IP       string
+	Tier     string
+	Rule     string
+	BodySize int64
+	Time     time.Time
+}
+
+// Result of the evaluation
+type Result struct {
+	// Key is empty if the key expression is not set
+	Key string
+
+	// Cost is 1 if the cost expression is not set
+	Cost int
+
+	// Limit is 0 (the configured limit) if the limit expression is not set
+	Limit int
+}
+
+// Expressions are the compiled and type-checked expressions.
+type Expressions struct {
+	key   cel.Program
+	cost  cel.Program
+	limit cel.Program
+}
+
+func newEnv() (*cel.Env, error) {
+	stringMap := cel.MapType(cel.StringType, cel.StringType)
+
+	return cel.NewEnv(
+		cel.Variable("method", cel.StringType),
+		cel.Variable("path", cel.StringType),
+		cel.Variable("route", cel.StringType),
+		cel.Variable("headers", stringMap),
+		cel.Variable("query", stringMap),
+		cel.Variable("client_id", cel.StringType),
+		cel.Variable("ip", cel.StringType),
+		cel.Variable("tier", cel.StringType),
+		cel.Variable("rule", cel.StringType),
+		cel.Variable("body_size", cel.IntType),
+		cel.Variable("now", cel.TimestampType),
+	)
+}
+
+// Compile compiles the expressions and checks their result types.
+func Compile(source Source) (*Expressions, error) {
+	env, err := newEnv()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create the CEL environment")
+	}
+
+	e := &Expressions{}
+	e.key, err = compile(env, "key", source.Key, cel.StringType)
+	if err != nil {
+		return nil, err
+	}
+
+	e.cost, err = compile(env, "cost", source.Cost, cel.IntType)
+	if err != nil {
+		return nil, err
+	}
+
+	e.limit, err = compile(env, "limit", source.Limit, cel.IntType)
+	if err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+func compile(env *cel.Env, name, source string, outputType *cel.Type) (cel.Program, error) {
+	if strings.TrimSpace(source) == "" {
+		return nil, nil
+	}
+
+	ast, issues := env.Compile(source)
+	if issues.Err() != nil {
+		return nil, errors.Errorf("invalid %s expression: %s", name, issues.Err())
+	}
+
+	if !ast.OutputType().IsExactType(outputType) {
+		return nil, errors.Errorf("%s expression must return %s, not %s", name, outputType, ast.OutputType())
+	}
+
+	program, err := env.Program(ast, cel.CostLimit(CostLimit))
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid %s expression", name)
+	}
+
+	return program, nil
+}
+
+// Evaluate evaluates the expressions with the request.
+func (e *Expressions) Evaluate(req Request) (Result, error) {
+	result := Result{Cost: 1}
+	vars := variables(req)
+
+	if e.key != nil {
+		value, _, err := e.key.Eval(vars)
+		if err != nil {
+			return Result{}, errors.Wrap(err, "failed to evaluate the key expression")
+		}
+
+		result.Key = value.Value().(string)
+	}
+
+	if e.cost != nil {
+		value, _, err := e.cost.Eval(vars)
+		if err != nil {
+			return Result{}, errors.Wrap(err, "failed to evaluate the cost expression")
+		}
+
+		result.Cost = int(value.Value().(int64))
+		if result.Cost < 0 {
+			return Result{}, errors.Errorf("negative cost: %d", result.Cost)
+		}
+	}
+
+	if e.limit != nil {
+		value, _, err := e.limit.Eval(vars)
+		if err != nil {
+			return Result{}, errors.Wrap(err, "failed to evaluate the limit expression")
+		}
+
+		result.Limit = int(value.Value().(int64))
+		if result.Limit < 0 {
+			return Result{}, errors.Errorf("negative limit: %d", result.Limit)
+		}
+	}
+
+	return result, nil
+}
+
+func variables(req Request) map[string]any {
+	headers := make(map[string]string, len(req.Header))
+	for name, values := range req.Header {
+		if len(values) > 0 {
+			headers[strings.ToLower(name)] = values[0]
+		}
+	}
+
+	query := make(map[string]string, len(req.Query))
+	for name, values := range req.Query {
+		if len(values) > 0 {
+			query[name] = values[0]
+		}
+	}
+
+	return map[string]any{
+		"method":    req.Method,
+		"path":      req.Path,
+		"route":     req.Route,
+		"headers":   headers,
+		"query":     query,
+		"client_id": req.ClientID,
+		"ip":        req.IP,
+		"tier":      req.Tier,
+		"rule":      req.Rule,
+		"body_size": req.BodySize,
+		"now":       req.Time,
+	}
+}
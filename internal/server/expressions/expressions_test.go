@@ -0,0 +1,103 @@
+package expressions
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompile_TypeCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		source Source
+		err    string
+	}{
+		{name: "empty", source: Source{}},
+		{name: "valid", source: Source{Key: `client_id + ":" + route`, Cost: `method == "POST" ? 5 : 1`, Limit: `tier == "premium" ? 1000 : 100`}},
+		{name: "syntax error", source: Source{Key: `client_id +`}, err: "invalid key expression"},
+		{name: "unknown variable", source: Source{Cost: `size > 10 ? 2 : 1`}, err: "invalid cost expression"},
+		{name: "wrong type", source: Source{Limit: `"100"`}, err: "limit expression must return int, not string"},
+		{name: "wrong key type", source: Source{Key: `body_size`}, err: "key expression must return string, not int"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Compile(test.source)
+			if test.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, test.err)
+			}
+		})
+	}
+}
+
+func TestExpressions_Evaluate(t *testing.T) {
+	e, err := Compile(Source{
+		Key:   `"x-tenant" in headers ? headers["x-tenant"] : client_id`,
+		Cost:  `method == "POST" && body_size > 1048576 ? 5 : 1`,
+		Limit: `now.getHours("UTC") >= 9 && now.getHours("UTC") < 17 ? 100 : 1000`,
+	})
+	assert.NoError(t, err)
+
+	req := Request{
+		Method:   http.MethodPost,
+		Path:     "/upload",
+		Header:   http.Header{"X-Tenant": {"acme"}},
+		Query:    url.Values{},
+		ClientID: "client",
+		BodySize: 2 << 20,
+		Time:     time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC),
+	}
+
+	result, err := e.Evaluate(req)
+	assert.NoError(t, err)
+	assert.Equal(t, Result{Key: "acme", Cost: 5, Limit: 100}, result)
+
+	req.Header = http.Header{}
+	req.BodySize = 1024
+	req.Time = time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC)
+
+	result, err = e.Evaluate(req)
+	assert.NoError(t, err)
+	assert.Equal(t, Result{Key: "client", Cost: 1, Limit: 1000}, result)
+}
+
+func TestExpressions_EvaluateErrors(t *testing.T) {
+	e, err := Compile(Source{Key: `headers["x-tenant"]`})
+	assert.NoError(t, err)
+
+	_, err = e.Evaluate(Request{Header: http.Header{}})
+	assert.ErrorContains(t, err, "key expression")
+
+	e, err = Compile(Source{Cost: `-1`})
+	assert.NoError(t, err)
+
+	_, err = e.Evaluate(Request{})
+	assert.ErrorContains(t, err, "negative cost")
+
+	// The evaluation is aborted once it exceeds the cost limit
+	e, err = Compile(Source{Cost: `headers["x-data"].contains("needle") ? 5 : 1`})
+	assert.NoError(t, err)
+
+	_, err = e.Evaluate(Request{Header: http.Header{"X-Data": {strings.Repeat("x", 10*CostLimit)}}})
+	assert.ErrorContains(t, err, "cost limit")
+}
+
+func TestExpressions_UnknownBodySize(t *testing.T) {
+	e, err := Compile(Source{Cost: `body_size < 0 || body_size > 1048576 ? 5 : 1`})
+	assert.NoError(t, err)
+
+	// The chunked bodies have an unknown size
+	result, err := e.Evaluate(Request{BodySize: -1})
+	assert.NoError(t, err)
+	assert.Equal(t, 5, result.Cost)
+
+	result, err = e.Evaluate(Request{BodySize: 1024})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.Cost)
+}
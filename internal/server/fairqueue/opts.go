@@ -0,0 +1,51 @@
+package fairqueue
+
+import (
+	"time"
+)
+
+type Options func(*Queue)
+
+// WithCapacity sets the number of requests admitted at once.
+func WithCapacity(capacity int) Options {
+	return func(q *Queue) {
+		if capacity < 1 {
+			return
+		}
+
+		q.config.Capacity = capacity
+	}
+}
+
+// WithMaxLength sets the maximum number of waiting requests of all clients. Zero rejects the requests when the capacity is saturated.
+func WithMaxLength(length int) Options {
+	return func(q *Queue) {
+		if length < 0 {
+			return
+		}
+
+		q.config.MaxLength = length
+	}
+}
+
+// WithMaxWait sets the maximum duration a request waits to be admitted.
+func WithMaxWait(wait time.Duration) Options {
+	return func(q *Queue) {
+		if wait <= 0 {
+			return
+		}
+
+		q.config.MaxWait = wait
+	}
+}
+
+// WithQuantum sets the cost a client can spend in every round.
+func WithQuantum(quantum int) Options {
+	return func(q *Queue) {
+		if quantum < 1 {
+			return
+		}
+
+		q.config.Quantum = quantum
+	}
+}
@@ -0,0 +1,286 @@
+// Package fairqueue admits the requests to a bounded backend capacity. When the capacity is saturated, the requests wait
+// in per-client queues, which are served with deficit round robin, so a client with many concurrent requests
+// can't crowd out the other clients.
+package fairqueue
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrQueueFull is returned when the queue is full, or when the request was evicted to make room for a client with a shorter queue
+	ErrQueueFull = errors.New("queue is full")
+
+	// ErrMaxWait is returned when the request waited for the maximum wait without being admitted
+	ErrMaxWait = errors.New("maximum wait exceeded")
+)
+
+// Config of the queue
+type Config struct {
+	// Capacity is the number of requests admitted at once
+	Capacity int
+
+	// MaxLength is the maximum number of waiting requests of all clients
+	MaxLength int
+
+	// MaxWait is the maximum duration a request waits to be admitted
+	MaxWait time.Duration
+
+	// Quantum is the cost a client can spend in every round
+	Quantum int
+}
+
+type waiter struct {
+	cost int
+
+	// result is nil when the request is admitted, or ErrQueueFull when it is evicted
+	result chan error
+}
+
+type clientQueue struct {
+	clientID string
+	waiters  []*waiter
+	deficit  int
+}
+
+// Queue is a fair admission queue keyed by the client ID.
+type Queue struct {
+	config Config
+
+	inFlight int
+	waiting  int
+
+	// clients is a map of client IDs to the queues of the clients with waiting requests
+	clients map[string]*clientQueue
+
+	// active are the client queues in the round robin order. The cursor points to the client being served.
+	active []*clientQueue
+	cursor int
+
+	mu sync.Mutex
+}
+
+// NewQueue creates a queue with the provided options
+func NewQueue(opts ...Options) *Queue {
+	q := &Queue{
+		config: Config{
+			Capacity:  100,
+			MaxLength: 1000,
+			MaxWait:   time.Second,
+			Quantum:   1,
+		},
+		clients: make(map[string]*clientQueue),
+	}
+
+	for _, opt := range opts {
+		opt(q)
+	}
+
+	return q
+}
+
+// Acquire admits the client's request with the cost, waiting in the client's queue if the capacity is saturated.
+// The release function must be called when the request completes. Calling it more than once has no effect.
+// Returns ErrQueueFull, ErrMaxWait or the error of the context if the request is not admitted.
+func (q *Queue) Acquire(ctx context.Context, clientID string, cost int) (release func(), err error) {
+	q.mu.Lock()
+	if q.inFlight < q.config.Capacity && q.waiting == 0 {
+		q.inFlight++
+		q.mu.Unlock()
+		return q.releaser(), nil
+	}
+
+	if q.waiting >= q.config.MaxLength && !q.evictFor(clientID) {
+		q.mu.Unlock()
+		return nil, ErrQueueFull
+	}
+
+	w := &waiter{cost: max(cost, 1), result: make(chan error, 1)}
+	q.enqueue(clientID, w)
+	q.mu.Unlock()
+
+	timer := time.NewTimer(q.config.MaxWait)
+	defer timer.Stop()
+
+	select {
+	case err := <-w.result:
+		if err != nil {
+			return nil, err
+		}
+
+		return q.releaser(), nil
+	case <-timer.C:
+		err = ErrMaxWait
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	q.mu.Lock()
+	removed := q.remove(clientID, w)
+	q.mu.Unlock()
+
+	// The request was admitted or evicted while giving up, so free up its capacity
+	if !removed {
+		if result := <-w.result; result == nil {
+			q.releaser()()
+		}
+	}
+
+	return nil, err
+}
+
+// releaser returns the function releasing the capacity of an admitted request.
+func (q *Queue) releaser() func() {
+	once := sync.Once{}
+	return func() {
+		once.Do(func() {
+			q.mu.Lock()
+			defer q.mu.Unlock()
+
+			q.inFlight--
+			q.dispatch()
+		})
+	}
+}
+
+// dispatch admits the waiting requests while there is capacity.
+func (q *Queue) dispatch() {
+	for q.inFlight < q.config.Capacity && q.waiting > 0 {
+		w := q.next()
+		q.inFlight++
+		w.result <- nil
+	}
+}
+
+// next dequeues the next request with deficit round robin. The client being served is served while its deficit
+// covers the cost of its next request. Every client gets the quantum added to its deficit when its turn comes.
+func (q *Queue) next() *waiter {
+	for {
+		cq := q.active[q.cursor]
+		w := cq.waiters[0]
+		if w.cost <= cq.deficit {
+			cq.deficit -= w.cost
+			cq.waiters = cq.waiters[1:]
+			q.waiting--
+			if len(cq.waiters) == 0 {
+				q.deactivate(cq)
+			}
+
+			return w
+		}
+
+		q.cursor = (q.cursor + 1) % len(q.active)
+		q.active[q.cursor].deficit += q.config.Quantum
+	}
+}
+
+func (q *Queue) enqueue(clientID string, w *waiter) {
+	cq, exists := q.clients[clientID]
+	if !exists {
+		cq = &clientQueue{clientID: clientID}
+		q.clients[clientID] = cq
+		q.active = append(q.active, cq)
+	}
+
+	cq.waiters = append(cq.waiters, w)
+	q.waiting++
+}
+
+// remove removes the waiting request. Returns false if the request is not waiting anymore.
+func (q *Queue) remove(clientID string, w *waiter) bool {
+	cq, exists := q.clients[clientID]
+	if !exists {
+		return false
+	}
+
+	for i, queued := range cq.waiters {
+		if queued != w {
+			continue
+		}
+
+		cq.waiters = append(cq.waiters[:i], cq.waiters[i+1:]...)
+		q.waiting--
+		if len(cq.waiters) == 0 {
+			q.deactivate(cq)
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// deactivate removes the client without waiting requests from the round robin.
+func (q *Queue) deactivate(cq *clientQueue) {
+	delete(q.clients, cq.clientID)
+
+	for i, active := range q.active {
+		if active != cq {
+			continue
+		}
+
+		q.active = append(q.active[:i], q.active[i+1:]...)
+		switch {
+		case len(q.active) == 0:
+			q.cursor = 0
+		case i < q.cursor:
+			q.cursor--
+		case i == q.cursor:
+			// The turn passes to the next client
+			q.cursor %= len(q.active)
+			q.active[q.cursor].deficit += q.config.Quantum
+		}
+
+		return
+	}
+}
+
+// evictFor evicts the newest request of the client with the longest queue, if its queue is longer than the client's
+// queue would be with the new request. Returns false if no request was evicted.
+func (q *Queue) evictFor(clientID string) bool {
+	var longest *clientQueue
+	for _, cq := range q.active {
+		if longest == nil || len(cq.waiters) > len(longest.waiters) {
+			longest = cq
+		}
+	}
+
+	length := 0
+	if cq, exists := q.clients[clientID]; exists {
+		length = len(cq.waiters)
+	}
+
+	if longest == nil || len(longest.waiters) <= length+1 {
+		return false
+	}
+
+	w := longest.waiters[len(longest.waiters)-1]
+	q.remove(longest.clientID, w)
+	w.result <- ErrQueueFull
+	return true
+}
+
+// InFlight returns the number of admitted requests.
+func (q *Queue) InFlight() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.inFlight
+}
+
+// MaxLength returns the maximum number of waiting requests of all clients.
+func (q *Queue) MaxLength() int {
+	return q.config.MaxLength
+}
+
+// Waiting returns the number of waiting requests of all clients.
+func (q *Queue) Waiting() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.waiting
+}
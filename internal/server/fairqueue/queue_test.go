@@ -0,0 +1,147 @@
+package fairqueue
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// waitFor waits until the number of waiting requests reaches the expected number.
+func waitFor(t *testing.T, q *Queue, waiting int) {
+	assert.Eventually(t, func() bool { return q.Waiting() == waiting }, time.Second, time.Millisecond)
+}
+
+func TestQueue_RoundRobin(t *testing.T) {
+	q := NewQueue(WithCapacity(1), WithMaxWait(time.Minute))
+	release, err := q.Acquire(context.Background(), "noisy", 1)
+	assert.NoError(t, err)
+
+	mu := sync.Mutex{}
+	order := []string{}
+	wg := sync.WaitGroup{}
+	acquire := func(clientID string) {
+		defer wg.Done()
+
+		release, err := q.Acquire(context.Background(), clientID, 1)
+		assert.NoError(t, err)
+
+		mu.Lock()
+		order = append(order, clientID)
+		mu.Unlock()
+		release()
+	}
+
+	// The noisy client queues its requests before the quiet client
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go acquire("noisy")
+		waitFor(t, q, i+1)
+	}
+
+	wg.Add(1)
+	go acquire("quiet")
+	waitFor(t, q, 4)
+
+	release()
+	wg.Wait()
+
+	assert.Equal(t, []string{"quiet", "noisy", "noisy", "noisy"}, order)
+	assert.Equal(t, 0, q.InFlight())
+	assert.Equal(t, 0, q.Waiting())
+}
+
+func TestQueue_Cost(t *testing.T) {
+	q := NewQueue(WithCapacity(1), WithMaxWait(time.Minute))
+	release, _ := q.Acquire(context.Background(), "1", 1)
+
+	mu := sync.Mutex{}
+	order := []string{}
+	wg := sync.WaitGroup{}
+	acquire := func(clientID string, cost int) {
+		defer wg.Done()
+
+		release, err := q.Acquire(context.Background(), clientID, cost)
+		assert.NoError(t, err)
+
+		mu.Lock()
+		order = append(order, clientID)
+		mu.Unlock()
+		release()
+	}
+
+	// The expensive request waits until its client saved up for it
+	wg.Add(3)
+	go acquire("expensive", 3)
+	waitFor(t, q, 1)
+	go acquire("cheap", 1)
+	waitFor(t, q, 2)
+	go acquire("cheap", 1)
+	waitFor(t, q, 3)
+
+	release()
+	wg.Wait()
+
+	assert.Equal(t, []string{"cheap", "cheap", "expensive"}, order)
+}
+
+func TestQueue_MaxWait(t *testing.T) {
+	q := NewQueue(WithCapacity(1), WithMaxWait(10*time.Millisecond))
+	release, _ := q.Acquire(context.Background(), "1", 1)
+
+	_, err := q.Acquire(context.Background(), "2", 1)
+	assert.ErrorIs(t, err, ErrMaxWait)
+	assert.Equal(t, 0, q.Waiting())
+
+	// The client disconnected while waiting
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = q.Acquire(ctx, "2", 1)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 0, q.Waiting())
+
+	// Releasing more than once has no effect
+	release()
+	release()
+	assert.Equal(t, 0, q.InFlight())
+}
+
+func TestQueue_Full(t *testing.T) {
+	q := NewQueue(WithCapacity(1), WithMaxLength(2), WithMaxWait(time.Minute))
+	release, _ := q.Acquire(context.Background(), "noisy", 1)
+
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			release, err := q.Acquire(context.Background(), "noisy", 1)
+			if err == nil {
+				release()
+			}
+			errs <- err
+		}()
+		waitFor(t, q, i+1)
+	}
+
+	// The noisy client can't queue more requests
+	_, err := q.Acquire(context.Background(), "noisy", 1)
+	assert.ErrorIs(t, err, ErrQueueFull)
+
+	// The newest request of the noisy client makes room for the quiet client
+	quiet := make(chan error)
+	go func() {
+		release, err := q.Acquire(context.Background(), "quiet", 1)
+		if err == nil {
+			release()
+		}
+		quiet <- err
+	}()
+	assert.ErrorIs(t, <-errs, ErrQueueFull)
+	waitFor(t, q, 2)
+
+	release()
+	assert.NoError(t, <-quiet)
+	assert.NoError(t, <-errs)
+	assert.Equal(t, 0, q.InFlight())
+}
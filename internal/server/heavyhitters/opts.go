@@ -0,0 +1,31 @@
+package heavyhitters
+
+import (
+	"time"
+)
+
+type Options func(*Tracker)
+
+// WithCapacity sets the number of counters per interval. Clients with more than 1/capacity of the requests are always tracked.
+func WithCapacity(capacity int) Options {
+	return func(t *Tracker) {
+		if capacity < 1 {
+			return
+		}
+
+		t.capacity = capacity
+	}
+}
+
+// WithIntervals sets the length and the number of the intervals the counts are kept for.
+func WithIntervals(interval time.Duration, intervals int) Options {
+	return func(t *Tracker) {
+		// Don't apply intervals less than 100ms
+		if interval < 100*time.Millisecond || intervals < 1 {
+			return
+		}
+
+		t.interval = interval
+		t.buckets = make([]bucket, intervals)
+	}
+}
@@ -0,0 +1,94 @@
+package heavyhitters
+
+import (
+	"container/heap"
+)
+
+type counter struct {
+	clientID string
+	count    uint64
+
+	// overestimation is the maximum overestimation of the count, inherited from the evicted counter
+	overestimation uint64
+
+	// index in the heap
+	index int
+}
+
+// summary is a space-saving summary, which keeps a fixed number of counters.
+// When all counters are taken, the counter with the lowest count is reassigned to the new client.
+// The count of every client with a frequency above count/capacity is guaranteed to be tracked.
+type summary struct {
+	capacity int
+	counters map[string]*counter
+
+	// minHeap orders the counters by their count
+	minHeap counterHeap
+}
+
+func newSummary(capacity int) *summary {
+	return &summary{
+		capacity: capacity,
+		counters: make(map[string]*counter, capacity),
+		minHeap:  make(counterHeap, 0, capacity),
+	}
+}
+
+func (s *summary) record(clientID string) {
+	c, exists := s.counters[clientID]
+	switch {
+	case exists:
+		c.count++
+		heap.Fix(&s.minHeap, c.index)
+	case len(s.minHeap) < s.capacity:
+		c = &counter{clientID: clientID, count: 1}
+		s.counters[clientID] = c
+		heap.Push(&s.minHeap, c)
+	default:
+		// Replace the client with the lowest count
+		c = s.minHeap[0]
+		delete(s.counters, c.clientID)
+
+		c.clientID = clientID
+		c.overestimation = c.count
+		c.count++
+		s.counters[clientID] = c
+		heap.Fix(&s.minHeap, 0)
+	}
+}
+
+// missing returns the maximum count of a client without a counter. A client is only missing from a full summary
+// if its counter was reassigned, so its count was at most the lowest count of the summary.
+func (s *summary) missing() uint64 {
+	if len(s.minHeap) < s.capacity {
+		return 0
+	}
+
+	return s.minHeap[0].count
+}
+
+// counterHeap implements heap.Interface
+type counterHeap []*counter
+
+func (h counterHeap) Len() int { return len(h) }
+
+func (h counterHeap) Less(i, j int) bool { return h[i].count < h[j].count }
+
+func (h counterHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *counterHeap) Push(x any) {
+	c := x.(*counter)
+	c.index = len(*h)
+	*h = append(*h, c)
+}
+
+func (h *counterHeap) Pop() any {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
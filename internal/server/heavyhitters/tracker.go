@@ -0,0 +1,134 @@
+package heavyhitters
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// HeavyHitter is a client with one of the highest request counts
+type HeavyHitter struct {
+	ClientID string `json:"clientId"`
+
+	// Count is the estimated number of requests. The real count is between Count-Overestimation and Count.
+	Count uint64 `json:"count"`
+
+	// Overestimation is the upper bound of the error of the count
+	Overestimation uint64 `json:"overestimation"`
+}
+
+type bucket struct {
+	start   time.Time
+	summary *summary
+}
+
+// Tracker tracks the clients with the most requests over sliding intervals in bounded memory.
+// Each interval has a space-saving summary with a fixed number of counters, so the memory does not depend on the number of clients.
+type Tracker struct {
+	capacity int
+	interval time.Duration
+
+	// buckets is a ring of the summaries of the most recent intervals
+	buckets []bucket
+
+	mu  sync.Mutex
+	now func() time.Time
+}
+
+// NewTracker creates a new heavy-hitters tracker with the provided options
+func NewTracker(opts ...Options) *Tracker {
+	t := &Tracker{
+		capacity: 100,
+		interval: time.Second * 10,
+		buckets:  make([]bucket, 6),
+		now:      time.Now,
+	}
+
+	// Apply options
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	return t
+}
+
+// Record records a request of the client
+func (t *Tracker) Record(clientID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	start := t.now().Truncate(t.interval)
+	b := &t.buckets[int(start.UnixNano()/int64(t.interval))%len(t.buckets)]
+
+	// Reuse the bucket of an old interval
+	if b.summary == nil || !b.start.Equal(start) {
+		b.start = start
+		b.summary = newSummary(t.capacity)
+	}
+
+	b.summary.record(clientID)
+}
+
+// MaxWindow returns the longest window the tracker keeps the counts for
+func (t *Tracker) MaxWindow() time.Duration {
+	return t.interval * time.Duration(len(t.buckets))
+}
+
+// Top returns the n clients with the most requests within the window, ordered by their count.
+// The window is rounded up to the whole intervals and limited by the MaxWindow.
+func (t *Tracker) Top(n int, window time.Duration) []HeavyHitter {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Merge the summaries of the intervals within the window
+	oldest := t.now().Truncate(t.interval).Add(-window + t.interval)
+	summaries := []*summary{}
+	merged := map[string]*HeavyHitter{}
+	for _, b := range t.buckets {
+		if b.summary == nil || b.start.Before(oldest) || b.start.Before(t.now().Add(-t.MaxWindow())) {
+			continue
+		}
+
+		summaries = append(summaries, b.summary)
+		for clientID, c := range b.summary.counters {
+			hitter, exists := merged[clientID]
+			if !exists {
+				hitter = &HeavyHitter{ClientID: clientID}
+				merged[clientID] = hitter
+			}
+
+			hitter.Count += c.count
+			hitter.Overestimation += c.overestimation
+		}
+	}
+
+	// The requests of a client missing from a summary could have been counted by a reassigned counter,
+	// so the count and the error bound grow by the most the client could have had in that summary
+	for _, hitter := range merged {
+		for _, s := range summaries {
+			if _, exists := s.counters[hitter.ClientID]; !exists {
+				hitter.Count += s.missing()
+				hitter.Overestimation += s.missing()
+			}
+		}
+	}
+
+	top := make([]HeavyHitter, 0, len(merged))
+	for _, hitter := range merged {
+		top = append(top, *hitter)
+	}
+
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Count == top[j].Count {
+			return top[i].ClientID < top[j].ClientID
+		}
+
+		return top[i].Count > top[j].Count
+	})
+
+	if len(top) > n {
+		top = top[:n]
+	}
+
+	return top
+}
@@ -0,0 +1,89 @@
+package heavyhitters
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTracker_Top(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	tracker := NewTracker(WithCapacity(3), WithIntervals(time.Second, 3))
+	tracker.now = func() time.Time { return now }
+
+	// Two heavy hitters among many clients with a single request
+	for i := 0; i < 100; i++ {
+		tracker.Record("heavy-1")
+		tracker.Record(fmt.Sprintf("light-%d", i))
+		if i%2 == 0 {
+			tracker.Record("heavy-2")
+		}
+	}
+
+	top := tracker.Top(2, time.Second)
+	assert.Len(t, top, 2)
+	assert.Equal(t, "heavy-1", top[0].ClientID)
+	assert.Equal(t, "heavy-2", top[1].ClientID)
+
+	// The real count is within the error bound
+	assert.GreaterOrEqual(t, top[0].Count, uint64(100))
+	assert.LessOrEqual(t, top[0].Count-top[0].Overestimation, uint64(100))
+
+	// The memory is bounded by the capacity
+	assert.Len(t, tracker.Top(10, time.Second), 3)
+}
+
+func TestTracker_SlidingIntervals(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	tracker := NewTracker(WithCapacity(10), WithIntervals(time.Second, 3))
+	tracker.now = func() time.Time { return now }
+	assert.Equal(t, 3*time.Second, tracker.MaxWindow())
+
+	tracker.Record("1")
+	tracker.Record("1")
+
+	now = now.Add(time.Second)
+	tracker.Record("2")
+
+	// Only the current interval
+	top := tracker.Top(10, time.Second)
+	assert.Equal(t, []HeavyHitter{{ClientID: "2", Count: 1}}, top)
+
+	// Both intervals
+	top = tracker.Top(10, 2*time.Second)
+	assert.Equal(t, []HeavyHitter{{ClientID: "1", Count: 2}, {ClientID: "2", Count: 1}}, top)
+
+	// The first interval slides out of the window
+	now = now.Add(2 * time.Second)
+	tracker.Record("2")
+	top = tracker.Top(10, time.Minute)
+	assert.Equal(t, []HeavyHitter{{ClientID: "2", Count: 2}}, top)
+}
+
+func TestTracker_MergeErrorBound(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	tracker := NewTracker(WithCapacity(2), WithIntervals(time.Second, 3))
+	tracker.now = func() time.Time { return now }
+
+	for i := 0; i < 5; i++ {
+		tracker.Record("1")
+	}
+
+	// The second interval is full without client 1
+	now = now.Add(time.Second)
+	for i := 0; i < 3; i++ {
+		tracker.Record("2")
+		tracker.Record("3")
+	}
+
+	// Client 1 could have had up to 3 requests counted by a reassigned counter of the second interval.
+	// The first interval is not full, so the other clients had no requests in it.
+	top := tracker.Top(3, 2*time.Second)
+	assert.Equal(t, []HeavyHitter{
+		{ClientID: "1", Count: 8, Overestimation: 3},
+		{ClientID: "2", Count: 3},
+		{ClientID: "3", Count: 3},
+	}, top)
+}
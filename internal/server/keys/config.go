@@ -0,0 +1,71 @@
+package keys
+
+import (
+	"github.com/pkg/errors"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/config"
+)
+
+// FromConfig creates the extractor from the configuration. The fallback extractors are used if the primary key is missing.
+func FromConfig(cfg config.Key) (Extractor, error) {
+	extractor, err := fromConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cfg.Fallback) == 0 {
+		return extractor, nil
+	}
+
+	extractors := []Extractor{extractor}
+	for _, fallbackConfig := range cfg.Fallback {
+		fallback, err := FromConfig(fallbackConfig)
+		if err != nil {
+			return nil, err
+		}
+
+		extractors = append(extractors, fallback)
+	}
+
+	return Fallback(extractors...), nil
+}
+
+func fromConfig(cfg config.Key) (Extractor, error) {
+	switch cfg.Source {
+	case "query":
+		return Query(cfg.Name), nil
+	case "header":
+		return Header(cfg.Name), nil
+	case "cookie":
+		return Cookie(cfg.Name), nil
+	case "ip":
+		return RemoteIP(), nil
+	case "route":
+		return Route(), nil
+	case "api-key":
+		return APIKey(orDefault(cfg.Name, "X-API-Key")), nil
+	case "jwt":
+		return JWTClaim(orDefault(cfg.Name, "Authorization"), cfg.Claim, []byte(cfg.Secret)), nil
+	case "composite":
+		parts := []Extractor{}
+		for _, partConfig := range cfg.Parts {
+			part, err := FromConfig(partConfig)
+			if err != nil {
+				return nil, err
+			}
+
+			parts = append(parts, part)
+		}
+
+		return Composite(parts...), nil
+	default:
+		return nil, errors.Errorf("unsupported key source: %s", cfg.Source)
+	}
+}
+
+func orDefault(value, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+
+	return value
+}
@@ -0,0 +1,159 @@
+package keys
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Extractor extracts the limiter key (e.g. the client ID) from the request.
+type Extractor interface {
+	Extract(ctx *gin.Context) (string, bool)
+}
+
+// ExtractorFunc is a function adapter for the Extractor interface
+type ExtractorFunc func(ctx *gin.Context) (string, bool)
+
+func (f ExtractorFunc) Extract(ctx *gin.Context) (string, bool) {
+	return f(ctx)
+}
+
+// Query extracts the key from a query parameter.
+func Query(name string) Extractor {
+	return ExtractorFunc(func(ctx *gin.Context) (string, bool) {
+		value := ctx.Query(name)
+		return value, value != ""
+	})
+}
+
+// Header extracts the key from a header (e.g. X-Client-ID).
+func Header(name string) Extractor {
+	return ExtractorFunc(func(ctx *gin.Context) (string, bool) {
+		value := ctx.GetHeader(name)
+		return value, value != ""
+	})
+}
+
+// Cookie extracts the key from a cookie.
+func Cookie(name string) Extractor {
+	return ExtractorFunc(func(ctx *gin.Context) (string, bool) {
+		value, err := ctx.Cookie(name)
+		return value, err == nil && value != ""
+	})
+}
+
+// RemoteIP extracts the client's IP address. The X-Forwarded-For and X-Real-IP headers are only respected for the requests
+// coming from the trusted proxies of the router (see gin.Engine.SetTrustedProxies). Otherwise, the IP of the connection is used,
+// so the clients can't pick a new key with every request.
+func RemoteIP() Extractor {
+	return ExtractorFunc(func(ctx *gin.Context) (string, bool) {
+		ip := ctx.ClientIP()
+		return ip, ip != ""
+	})
+}
+
+// Route extracts the route pattern (e.g. /users/:id) the request was matched with.
+func Route() Extractor {
+	return ExtractorFunc(func(ctx *gin.Context) (string, bool) {
+		route := ctx.FullPath()
+		return route, route != ""
+	})
+}
+
+// APIKey extracts the API key from a header. The key is hashed, so it doesn't leak into the limiter state, logs or the admin API.
+// If the header is Authorization, the ApiKey or Bearer scheme prefix is removed.
+func APIKey(header string) Extractor {
+	return ExtractorFunc(func(ctx *gin.Context) (string, bool) {
+		value := ctx.GetHeader(header)
+		if strings.EqualFold(header, "Authorization") {
+			value = strings.TrimSpace(trimScheme(value, "ApiKey", "Bearer"))
+		}
+
+		if value == "" {
+			return "", false
+		}
+
+		hash := sha256.Sum256([]byte(value))
+		return "apikey:" + hex.EncodeToString(hash[:8]), true
+	})
+}
+
+// JWTClaim extracts a claim from the bearer token in the header. The token must be signed with the HMAC secret.
+// If the secret is empty, the signature is not verified, so the claim should only be used for keying, never for authorization.
+func JWTClaim(header, claim string, secret []byte) Extractor {
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{"HS256", "HS384", "HS512"}))
+
+	return ExtractorFunc(func(ctx *gin.Context) (string, bool) {
+		token := strings.TrimSpace(trimScheme(ctx.GetHeader(header), "Bearer"))
+		if token == "" {
+			return "", false
+		}
+
+		claims := jwt.MapClaims{}
+		var err error
+		if len(secret) == 0 {
+			_, _, err = parser.ParseUnverified(token, claims)
+		} else {
+			_, err = parser.ParseWithClaims(token, claims, func(*jwt.Token) (any, error) {
+				return secret, nil
+			})
+		}
+
+		if err != nil {
+			return "", false
+		}
+
+		value, exists := claims[claim]
+		if !exists || value == nil {
+			return "", false
+		}
+
+		key := fmt.Sprint(value)
+		return key, key != ""
+	})
+}
+
+// Composite joins the keys of all the extractors (e.g. client ID and route). The key is missing if any of the parts is missing.
+func Composite(extractors ...Extractor) Extractor {
+	return ExtractorFunc(func(ctx *gin.Context) (string, bool) {
+		parts := make([]string, 0, len(extractors))
+		for _, extractor := range extractors {
+			part, isFound := extractor.Extract(ctx)
+			if !isFound {
+				return "", false
+			}
+
+			parts = append(parts, part)
+		}
+
+		return strings.Join(parts, "|"), len(parts) > 0
+	})
+}
+
+// Fallback returns the key of the first extractor that finds it.
+func Fallback(extractors ...Extractor) Extractor {
+	return ExtractorFunc(func(ctx *gin.Context) (string, bool) {
+		for _, extractor := range extractors {
+			if key, isFound := extractor.Extract(ctx); isFound {
+				return key, true
+			}
+		}
+
+		return "", false
+	})
+}
+
+// trimScheme removes the authorization scheme prefix (e.g. Bearer) from the header value.
+func trimScheme(value string, schemes ...string) string {
+	for _, scheme := range schemes {
+		if len(value) > len(scheme) && strings.EqualFold(value[:len(scheme)], scheme) && value[len(scheme)] == ' ' {
+			return value[len(scheme)+1:]
+		}
+	}
+
+	return value
+}
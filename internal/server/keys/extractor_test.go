@@ -0,0 +1,163 @@
+package keys
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/config"
+)
+
+// extract runs the extractor on the request, matched with the route
+func extract(t *testing.T, extractor Extractor, route string, req *http.Request) (string, bool) {
+	t.Helper()
+
+	var (
+		key     string
+		isFound bool
+	)
+	r := gin.New()
+	r.GET(route, func(ctx *gin.Context) {
+		key, isFound = extractor.Extract(ctx)
+	})
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	return key, isFound
+}
+
+func signedToken(t *testing.T, claims jwt.MapClaims, secret string) string {
+	t.Helper()
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	assert.NoError(t, err)
+	return token
+}
+
+func TestExtractors(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/users/42?clientId=query-client", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("X-Client-ID", "header-client")
+	req.Header.Set("X-API-Key", "secret-key")
+	req.AddCookie(&http.Cookie{Name: "session", Value: "cookie-client"})
+
+	tests := []struct {
+		name      string
+		extractor Extractor
+		key       string
+		isFound   bool
+	}{
+		{name: "query", extractor: Query("clientId"), key: "query-client", isFound: true},
+		{name: "missing query", extractor: Query("missing")},
+		{name: "header", extractor: Header("X-Client-ID"), key: "header-client", isFound: true},
+		{name: "cookie", extractor: Cookie("session"), key: "cookie-client", isFound: true},
+		{name: "missing cookie", extractor: Cookie("missing")},
+		{name: "remote ip", extractor: RemoteIP(), key: "10.0.0.1", isFound: true},
+		{name: "route", extractor: Route(), key: "/users/:id", isFound: true},
+		{name: "composite", extractor: Composite(Header("X-Client-ID"), Route()), key: "header-client|/users/:id", isFound: true},
+		{name: "composite with missing part", extractor: Composite(Header("X-Client-ID"), Query("missing"))},
+		{name: "fallback", extractor: Fallback(Query("missing"), Header("X-Client-ID")), key: "header-client", isFound: true},
+		{name: "missing fallback", extractor: Fallback(Query("missing"), Cookie("missing"))},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			key, isFound := extract(t, test.extractor, "/users/:id", req)
+			assert.Equal(t, test.isFound, isFound)
+			assert.Equal(t, test.key, key)
+		})
+	}
+}
+
+func TestRemoteIP_TrustedProxies(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.Header.Set("X-Forwarded-For", "10.0.0.1")
+
+	extractIP := func(trustedProxies []string) string {
+		key := ""
+		r := gin.New()
+		assert.NoError(t, r.SetTrustedProxies(trustedProxies))
+		r.GET("/", func(ctx *gin.Context) {
+			key, _ = RemoteIP().Extract(ctx)
+		})
+		r.ServeHTTP(httptest.NewRecorder(), req)
+
+		return key
+	}
+
+	// The spoofed header is ignored without the trusted proxies
+	assert.Equal(t, "192.0.2.1", extractIP(nil))
+	assert.Equal(t, "10.0.0.1", extractIP([]string{"192.0.2.0/24"}))
+}
+
+func TestAPIKey(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-API-Key", "secret-key")
+
+	key, isFound := extract(t, APIKey("X-API-Key"), "/", req)
+	assert.True(t, isFound)
+	assert.NotContains(t, key, "secret-key")
+
+	// The same key from the Authorization header maps to the same client
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "ApiKey secret-key")
+
+	authorizationKey, isFound := extract(t, APIKey("Authorization"), "/", req)
+	assert.True(t, isFound)
+	assert.Equal(t, key, authorizationKey)
+
+	_, isFound = extract(t, APIKey("X-API-Key"), "/", req)
+	assert.False(t, isFound)
+}
+
+func TestJWTClaim(t *testing.T) {
+	token := signedToken(t, jwt.MapClaims{"sub": "user-1", "tenant": 7}, "secret")
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	key, isFound := extract(t, JWTClaim("Authorization", "sub", []byte("secret")), "/", req)
+	assert.True(t, isFound)
+	assert.Equal(t, "user-1", key)
+
+	key, isFound = extract(t, JWTClaim("Authorization", "tenant", nil), "/", req)
+	assert.True(t, isFound)
+	assert.Equal(t, "7", key)
+
+	_, isFound = extract(t, JWTClaim("Authorization", "missing", []byte("secret")), "/", req)
+	assert.False(t, isFound)
+
+	// Tokens with an invalid signature are rejected
+	_, isFound = extract(t, JWTClaim("Authorization", "sub", []byte("other-secret")), "/", req)
+	assert.False(t, isFound)
+}
+
+func TestFromConfig(t *testing.T) {
+	extractor, err := FromConfig(config.Key{
+		Source: "composite",
+		Parts: []config.Key{
+			{Source: "header", Name: "X-Client-ID"},
+			{Source: "route"},
+		},
+		Fallback: []config.Key{{Source: "ip"}},
+	})
+	assert.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+
+	key, isFound := extract(t, extractor, "/orders", req)
+	assert.True(t, isFound)
+	assert.Equal(t, "10.0.0.1", key)
+
+	req.Header.Set("X-Client-ID", "client")
+	key, isFound = extract(t, extractor, "/orders", req)
+	assert.True(t, isFound)
+	assert.Equal(t, "client|/orders", key)
+
+	_, err = FromConfig(config.Key{Source: "unknown"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,190 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/rate-limiter"
+)
+
+const namespace = "rate_limiter"
+
+// Decision is the outcome of the handler for a request
+type Decision string
+
+const (
+	DecisionAllowed    Decision = "allowed"
+	DecisionLimited    Decision = "limited"
+	DecisionBadRequest Decision = "bad_request"
+	DecisionBanned     Decision = "banned"
+	DecisionDenied     Decision = "denied"
+	DecisionBypassed   Decision = "bypassed"
+
+	DecisionQuotaExceeded   Decision = "quota_exceeded"
+	DecisionShed            Decision = "shed"
+	DecisionQueueFull       Decision = "queue_full"
+	DecisionQueueTimeout    Decision = "queue_timeout"
+	DecisionTooManyInFlight Decision = "too_many_in_flight"
+
+	DecisionChallengeSolved Decision = "challenge_solved"
+)
+
+// Metrics collects the limiter decisions and the HTTP traffic metrics.
+// The client ID is never used as a label, so the cardinality of the metrics is bounded.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	decisions      *prometheus.CounterVec
+	limiterLatency *prometheus.HistogramVec
+	events         *prometheus.CounterVec
+
+	httpRequests *prometheus.CounterVec
+	httpDuration *prometheus.HistogramVec
+}
+
+// New creates and registers the metrics
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		decisions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "decisions_total",
+			Help:      "Number of decisions made by the handler.",
+		}, []string{"rule", "tier", "decision"}),
+		limiterLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "limiter_duration_seconds",
+			Help:      "Time spent deciding whether the request is limited.",
+			Buckets:   []float64{.00001, .000025, .00005, .0001, .00025, .0005, .001, .0025, .005, .01, .025, .05},
+		}, []string{"rule"}),
+		events: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "events_total",
+			Help:      "Number of limiter events, such as evicted or banned clients.",
+		}, []string{"event"}),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "Number of HTTP requests.",
+		}, []string{"method", "route", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Duration of the HTTP requests.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.decisions,
+		m.limiterLatency,
+		m.events,
+		m.httpRequests,
+		m.httpDuration,
+	)
+
+	return m
+}
+
+// Handler serves the metrics in the Prometheus format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// ObserveDecision records the decision of the handler and the time the limiter needed to make it.
+func (m *Metrics) ObserveDecision(rule, tier string, decision Decision, latency time.Duration) {
+	m.decisions.WithLabelValues(rule, tier, string(decision)).Inc()
+	if latency > 0 {
+		m.limiterLatency.WithLabelValues(rule).Observe(latency.Seconds())
+	}
+}
+
+// OnEvent counts the client evictions and bans.
+func (m *Metrics) OnEvent(event rate_limiter.Event) {
+	switch event.Type {
+	case rate_limiter.EventClientEvicted, rate_limiter.EventClientBanned, rate_limiter.EventWindowOpened:
+		m.events.WithLabelValues(string(event.Type)).Inc()
+	}
+}
+
+// TrackClients exposes the number of clients tracked by the limiter.
+func (m *Metrics) TrackClients(trackedClients func() int) {
+	m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "tracked_clients",
+		Help:      "Number of clients tracked by the limiter.",
+	}, func() float64 {
+		return float64(trackedClients())
+	}))
+}
+
+// TrackLoadShedding exposes the adaptive concurrency limit and the requests in flight.
+func (m *Metrics) TrackLoadShedding(limit, inFlight func() int) {
+	m.registry.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "concurrency_limit",
+			Help:      "Adaptive server-wide concurrency limit.",
+		}, func() float64 {
+			return float64(limit())
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "requests_in_flight",
+			Help:      "Number of requests in flight admitted by the adaptive limiter.",
+		}, func() float64 {
+			return float64(inFlight())
+		}),
+	)
+}
+
+// TrackQueue exposes the number of requests waiting in the fair queue.
+func (m *Metrics) TrackQueue(waiting func() int) {
+	m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "queued_requests",
+		Help:      "Number of requests waiting in the fair queue.",
+	}, func() float64 {
+		return float64(waiting())
+	}))
+}
+
+// TrackTarpit exposes the number of connections held by the tarpit.
+func (m *Metrics) TrackTarpit(held func() int) {
+	m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "tarpitted_connections",
+		Help:      "Number of connections held by the tarpit.",
+	}, func() float64 {
+		return float64(held())
+	}))
+}
+
+// TrackShadowStats exposes the decision counters of the shadow limiters.
+func (m *Metrics) TrackShadowStats(stats func() []rate_limiter.ShadowStats) {
+	m.registry.MustRegister(&shadowCollector{stats: stats})
+}
+
+// Middleware records the HTTP request metrics. Requests are labeled by their route pattern, not the path.
+func (m *Metrics) Middleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		ctx.Next()
+
+		route := ctx.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		method := ctx.Request.Method
+		m.httpRequests.WithLabelValues(method, route, strconv.Itoa(ctx.Writer.Status())).Inc()
+		m.httpDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/rate-limiter"
+	"go.uber.org/zap"
+)
+
+func scrape(t *testing.T, m *Metrics) string {
+	req, _ := http.NewRequest(http.MethodGet, "/metrics", nil)
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	return w.Body.String()
+}
+
+func TestMetrics(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	zap.ReplaceGlobals(logger)
+
+	m := New()
+	m.TrackClients(func() int { return 3 })
+	m.TrackLoadShedding(func() int { return 100 }, func() int { return 7 })
+	m.TrackQueue(func() int { return 4 })
+	m.TrackTarpit(func() int { return 2 })
+	m.TrackShadowStats(func() []rate_limiter.ShadowStats {
+		return []rate_limiter.ShadowStats{{Name: "candidate", Evaluated: 5, ShadowLimited: 2, Disagreements: 1}}
+	})
+
+	// Drive the metrics from the limiter events
+	rateLimiter := rate_limiter.NewSlidingWindowRateLimiter(rate_limiter.WithLimit(1), rate_limiter.WithObserver(m))
+	rateLimiter.IsLimited("secret-client")
+
+	m.ObserveDecision("default", "default", DecisionAllowed, time.Millisecond)
+	m.ObserveDecision("default", "default", DecisionBadRequest, 0)
+
+	r := gin.New()
+	r.Use(m.Middleware())
+	r.GET("/test/:id", func(ctx *gin.Context) { ctx.Status(http.StatusNoContent) })
+
+	req, _ := http.NewRequest(http.MethodGet, "/test/secret-client", nil)
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	body := scrape(t, m)
+	assert.Contains(t, body, `rate_limiter_decisions_total{decision="allowed",rule="default",tier="default"} 1`)
+	assert.Contains(t, body, `rate_limiter_decisions_total{decision="bad_request",rule="default",tier="default"} 1`)
+	assert.Contains(t, body, `rate_limiter_limiter_duration_seconds_count{rule="default"} 1`)
+	assert.Contains(t, body, `rate_limiter_events_total{event="window_opened"} 1`)
+	assert.Contains(t, body, `rate_limiter_tracked_clients 3`)
+	assert.Contains(t, body, `rate_limiter_concurrency_limit 100`)
+	assert.Contains(t, body, `rate_limiter_requests_in_flight 7`)
+	assert.Contains(t, body, `rate_limiter_queued_requests 4`)
+	assert.Contains(t, body, `rate_limiter_tarpitted_connections 2`)
+	assert.Contains(t, body, `rate_limiter_shadow_would_limit_total{name="candidate"} 2`)
+	assert.Contains(t, body, `rate_limiter_http_requests_total{method="GET",route="/test/:id",status="204"} 1`)
+
+	// Client IDs must never be used as labels
+	assert.NotContains(t, body, "secret-client")
+}
@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/rate-limiter"
+)
+
+var (
+	shadowEvaluatedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "shadow", "evaluated_total"),
+		"Number of requests evaluated by the shadow limiter.",
+		[]string{"name"}, nil,
+	)
+	shadowLimitedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "shadow", "would_limit_total"),
+		"Number of requests that would have been limited by the shadow limiter.",
+		[]string{"name"}, nil,
+	)
+	shadowDisagreementsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "shadow", "disagreements_total"),
+		"Number of requests the shadow and the enforcing limiter decided differently on.",
+		[]string{"name"}, nil,
+	)
+)
+
+// shadowCollector collects the decision counters of the shadow limiters on every scrape
+type shadowCollector struct {
+	stats func() []rate_limiter.ShadowStats
+}
+
+func (c *shadowCollector) Describe(descs chan<- *prometheus.Desc) {
+	descs <- shadowEvaluatedDesc
+	descs <- shadowLimitedDesc
+	descs <- shadowDisagreementsDesc
+}
+
+func (c *shadowCollector) Collect(metrics chan<- prometheus.Metric) {
+	for _, stats := range c.stats() {
+		metrics <- prometheus.MustNewConstMetric(shadowEvaluatedDesc, prometheus.CounterValue, float64(stats.Evaluated), stats.Name)
+		metrics <- prometheus.MustNewConstMetric(shadowLimitedDesc, prometheus.CounterValue, float64(stats.ShadowLimited), stats.Name)
+		metrics <- prometheus.MustNewConstMetric(shadowDisagreementsDesc, prometheus.CounterValue, float64(stats.Disagreements), stats.Name)
+	}
+}
@@ -0,0 +1,290 @@
+package penalty
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/rate-limiter"
+	"go.uber.org/zap"
+)
+
+// Config for the penalty box
+type Config struct {
+
+	// Threshold is the number of times a client can be limited within the observation period before it gets banned
+	Threshold int
+
+	// Windows is the number of rate limiter windows that make up the observation period
+	Windows int
+
+	// Window is the duration of a single rate limiter window
+	Window time.Duration
+
+	// BaseDuration is the duration of the first ban. Each repeated ban doubles the duration.
+	BaseDuration time.Duration
+
+	// MaxDuration is the upper bound for the ban duration
+	MaxDuration time.Duration
+
+	// ForgetAfter is the duration after the last ban expires, after which the client's offenses are forgotten
+	ForgetAfter time.Duration
+}
+
+// Ban describes an active ban of a client
+type Ban struct {
+	ClientID string    `json:"clientId"`
+	Offense  int       `json:"offense"`
+	Since    time.Time `json:"since"`
+	Until    time.Time `json:"until"`
+}
+
+type offender struct {
+	// Times when the client was limited within the observation period
+	limitedAt []time.Time
+
+	// Number of bans the client received so far
+	offenses int
+
+	// The current (or last) ban of the client
+	ban *Ban
+}
+
+// Box keeps track of clients that are repeatedly rate limited and bans them for an exponentially growing duration.
+type Box struct {
+	config Config
+
+	// offenders is a map of client IDs to their offense history
+	offenders map[string]*offender
+
+	// observers are notified when a client is banned
+	observers []rate_limiter.Observer
+
+	mu     sync.Mutex
+	logger *zap.Logger
+	now    func() time.Time
+}
+
+// NewBox creates a new penalty box with the provided options
+func NewBox(opts ...Options) *Box {
+	box := &Box{
+		config: Config{
+			Threshold:    10,
+			Windows:      3,
+			Window:       time.Second * 5,
+			BaseDuration: time.Minute,
+			MaxDuration:  time.Hour,
+			ForgetAfter:  time.Hour * 24,
+		},
+		offenders: make(map[string]*offender),
+		logger:    zap.L().Named("penalty-box"),
+		now:       time.Now,
+	}
+
+	// Apply options
+	for _, opt := range opts {
+		opt(box)
+	}
+
+	return box
+}
+
+// RecordLimited records that the client was rate limited. If the client exceeded the threshold, it is banned and the ban is returned.
+func (b *Box) RecordLimited(clientID string) (Ban, bool) {
+	b.mu.Lock()
+	ban, banned := b.recordLimited(clientID)
+	b.mu.Unlock()
+
+	if banned {
+		for _, observer := range b.observers {
+			observer.OnEvent(rate_limiter.Event{
+				Type:     rate_limiter.EventClientBanned,
+				ClientID: ban.ClientID,
+				Time:     ban.Since,
+				Until:    ban.Until,
+			})
+		}
+	}
+
+	return ban, banned
+}
+
+func (b *Box) recordLimited(clientID string) (Ban, bool) {
+	now := b.now()
+	o, exists := b.offenders[clientID]
+	if !exists {
+		o = &offender{}
+		b.offenders[clientID] = o
+	}
+
+	// Forget the offenses of clients that behaved for long enough
+	if o.ban != nil && now.Sub(o.ban.Until) > b.config.ForgetAfter {
+		o.offenses = 0
+		o.ban = nil
+	}
+
+	// Banned clients are not limited any further
+	if o.ban != nil && now.Before(o.ban.Until) {
+		return *o.ban, false
+	}
+
+	// Drop the offenses outside the observation period
+	o.limitedAt = append(b.recent(o, now), now)
+
+	if len(o.limitedAt) <= b.config.Threshold {
+		return Ban{}, false
+	}
+
+	// Ban the client
+	o.offenses++
+	o.limitedAt = nil
+	o.ban = &Ban{
+		ClientID: clientID,
+		Offense:  o.offenses,
+		Since:    now,
+		Until:    now.Add(b.banDuration(o.offenses)),
+	}
+
+	b.logger.Info("Client banned",
+		zap.String("clientId", clientID),
+		zap.Int("offense", o.offenses),
+		zap.Time("until", o.ban.Until),
+	)
+
+	return *o.ban, true
+}
+
+// recent returns the times the client was limited within the observation period. Must be called with the lock held.
+func (b *Box) recent(o *offender, now time.Time) []time.Time {
+	period := b.config.Window * time.Duration(b.config.Windows)
+	recent := o.limitedAt[:0]
+	for _, limitedAt := range o.limitedAt {
+		if now.Sub(limitedAt) <= period {
+			recent = append(recent, limitedAt)
+		}
+	}
+
+	return recent
+}
+
+// banDuration calculates the ban duration for the n-th offense
+func (b *Box) banDuration(offense int) time.Duration {
+	duration := b.config.BaseDuration
+	for i := 1; i < offense; i++ {
+		duration *= 2
+		if duration >= b.config.MaxDuration {
+			return b.config.MaxDuration
+		}
+	}
+
+	return min(duration, b.config.MaxDuration)
+}
+
+// IsBanned checks if the client is currently banned
+func (b *Box) IsBanned(clientID string) (Ban, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	o, exists := b.offenders[clientID]
+	if !exists || o.ban == nil || !b.now().Before(o.ban.Until) {
+		return Ban{}, false
+	}
+
+	return *o.ban, true
+}
+
+// Bans returns all active bans, ordered by their expiry
+func (b *Box) Bans() []Ban {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	bans := []Ban{}
+	for _, o := range b.offenders {
+		if o.ban != nil && now.Before(o.ban.Until) {
+			bans = append(bans, *o.ban)
+		}
+	}
+
+	sort.Slice(bans, func(i, j int) bool {
+		return bans[i].Until.Before(bans[j].Until)
+	})
+
+	return bans
+}
+
+// Lift lifts the active ban of the client. The client's offense count is kept, so a repeated ban is still escalated.
+func (b *Box) Lift(clientID string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	o, exists := b.offenders[clientID]
+	if !exists || o.ban == nil || !b.now().Before(o.ban.Until) {
+		return false
+	}
+
+	o.ban.Until = b.now()
+	b.logger.Info("Ban lifted", zap.String("clientId", clientID))
+	return true
+}
+
+// EvictExpired forgets the clients without offenses in the observation period, whose ban (if any) expired more than
+// ForgetAfter ago. Returns the number of forgotten clients.
+func (b *Box) EvictExpired() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	evicted := 0
+	for clientID, o := range b.offenders {
+		o.limitedAt = b.recent(o, now)
+		if len(o.limitedAt) > 0 || (o.ban != nil && now.Sub(o.ban.Until) <= b.config.ForgetAfter) {
+			continue
+		}
+
+		delete(b.offenders, clientID)
+		evicted++
+	}
+
+	return evicted
+}
+
+// SetWindow replaces the duration and the number of the windows that make up the observation period (e.g. when the
+// duration of the rate limiter windows changes). Invalid windows are ignored.
+func (b *Box) SetWindow(window time.Duration, windows int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	WithWindow(window, windows)(b)
+}
+
+// window returns the duration of a single window
+func (b *Box) window() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.config.Window
+}
+
+// Run forgets the clients without recent offenses once per window, until the context is done.
+func (b *Box) Run(ctx context.Context) {
+	window := b.window()
+	ticker := time.NewTicker(window)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			b.EvictExpired()
+
+			// Follow the changes of the window
+			if current := b.window(); current != window {
+				window = current
+				ticker.Reset(window)
+			}
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package penalty
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/rate-limiter"
+	"go.uber.org/zap"
+)
+
+func TestBox_EscalatingBans(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	zap.ReplaceGlobals(logger)
+
+	now := time.Now()
+	box := NewBox(WithThreshold(2), WithWindow(time.Second, 2), WithBanDuration(time.Minute, 3*time.Minute))
+	box.now = func() time.Time { return now }
+
+	// The first offenses within the threshold don't result in a ban
+	for i := 0; i < 2; i++ {
+		_, banned := box.RecordLimited("1")
+		assert.False(t, banned)
+	}
+
+	ban, banned := box.RecordLimited("1")
+	assert.True(t, banned)
+	assert.Equal(t, 1, ban.Offense)
+	assert.Equal(t, now.Add(time.Minute), ban.Until)
+
+	_, banned = box.IsBanned("1")
+	assert.True(t, banned)
+	_, banned = box.IsBanned("2")
+	assert.False(t, banned)
+
+	// The second ban should be twice as long
+	now = now.Add(2 * time.Minute)
+	_, banned = box.IsBanned("1")
+	assert.False(t, banned)
+
+	for i := 0; i < 3; i++ {
+		ban, banned = box.RecordLimited("1")
+	}
+	assert.True(t, banned)
+	assert.Equal(t, 2, ban.Offense)
+	assert.Equal(t, now.Add(2*time.Minute), ban.Until)
+
+	// The third ban is capped by the maximum duration
+	now = now.Add(3 * time.Minute)
+	for i := 0; i < 3; i++ {
+		ban, banned = box.RecordLimited("1")
+	}
+	assert.True(t, banned)
+	assert.Equal(t, 3, ban.Offense)
+	assert.Equal(t, now.Add(3*time.Minute), ban.Until)
+}
+
+func TestBox_OffensesOutsidePeriod(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	zap.ReplaceGlobals(logger)
+
+	now := time.Now()
+	box := NewBox(WithThreshold(2), WithWindow(time.Second, 2))
+	box.now = func() time.Time { return now }
+
+	// Offenses spread over a longer period than the observation period don't result in a ban
+	for i := 0; i < 10; i++ {
+		_, banned := box.RecordLimited("1")
+		assert.False(t, banned)
+		now = now.Add(time.Second + time.Millisecond)
+	}
+}
+
+func TestBox_EvictExpired(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	zap.ReplaceGlobals(logger)
+
+	now := time.Now()
+	box := NewBox(WithThreshold(1), WithWindow(time.Second, 2), WithBanDuration(time.Minute, time.Hour), WithForgetAfter(time.Hour))
+	box.now = func() time.Time { return now }
+
+	// Client 1 is limited once, client 2 is banned
+	box.RecordLimited("1")
+	box.RecordLimited("2")
+	box.RecordLimited("2")
+
+	// The offenses are kept within the observation period
+	assert.Equal(t, 0, box.EvictExpired())
+
+	// The offenses of client 1 lapsed, but client 2 is still banned
+	now = now.Add(3 * time.Second)
+	assert.Equal(t, 1, box.EvictExpired())
+	assert.Len(t, box.Bans(), 1)
+
+	// The offenses of client 2 are kept after the ban expires, so a repeated ban is still escalated
+	now = now.Add(time.Minute + time.Second)
+	assert.Equal(t, 0, box.EvictExpired())
+
+	now = now.Add(time.Hour)
+	assert.Equal(t, 1, box.EvictExpired())
+	assert.Empty(t, box.offenders)
+}
+
+func TestBox_SetWindow(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	zap.ReplaceGlobals(logger)
+
+	now := time.Now()
+	box := NewBox(WithThreshold(1), WithWindow(time.Minute, 2))
+	box.now = func() time.Time { return now }
+
+	box.RecordLimited("1")
+	now = now.Add(30 * time.Second)
+
+	// The offense lapses in the shorter observation period
+	box.SetWindow(10*time.Second, 2)
+	_, banned := box.RecordLimited("1")
+	assert.False(t, banned)
+	assert.Equal(t, 10*time.Second, box.window())
+
+	// Invalid windows are ignored
+	box.SetWindow(0, 2)
+	assert.Equal(t, 10*time.Second, box.window())
+}
+
+func TestBox_LiftAndList(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	zap.ReplaceGlobals(logger)
+
+	box := NewBox(WithThreshold(1))
+	assert.False(t, box.Lift("1"))
+
+	box.RecordLimited("1")
+	box.RecordLimited("1")
+	box.RecordLimited("2")
+	box.RecordLimited("2")
+
+	bans := box.Bans()
+	assert.Len(t, bans, 2)
+
+	assert.True(t, box.Lift("1"))
+	_, banned := box.IsBanned("1")
+	assert.False(t, banned)
+
+	bans = box.Bans()
+	assert.Len(t, bans, 1)
+	assert.Equal(t, "2", bans[0].ClientID)
+}
+
+func TestBox_Observer(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	zap.ReplaceGlobals(logger)
+
+	events := []rate_limiter.Event{}
+	observer := rate_limiter.ObserverFunc(func(event rate_limiter.Event) {
+		events = append(events, event)
+	})
+	box := NewBox(WithThreshold(1), WithObserver(observer))
+
+	box.RecordLimited("1")
+	assert.Empty(t, events)
+
+	ban, _ := box.RecordLimited("1")
+	assert.Len(t, events, 1)
+	assert.Equal(t, rate_limiter.EventClientBanned, events[0].Type)
+	assert.Equal(t, "1", events[0].ClientID)
+	assert.Equal(t, ban.Until, events[0].Until)
+}
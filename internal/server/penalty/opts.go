@@ -0,0 +1,65 @@
+package penalty
+
+import (
+	"time"
+
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/rate-limiter"
+)
+
+type Options func(*Box)
+
+func WithThreshold(threshold int) Options {
+	return func(b *Box) {
+		// Threshold must be greater than 0
+		if threshold < 1 {
+			return
+		}
+
+		b.config.Threshold = threshold
+	}
+}
+
+func WithWindow(window time.Duration, windows int) Options {
+	return func(b *Box) {
+		// Don't apply windows less than 100ms
+		if window < 100*time.Millisecond || windows < 1 {
+			return
+		}
+
+		b.config.Window = window
+		b.config.Windows = windows
+	}
+}
+
+func WithBanDuration(base, max time.Duration) Options {
+	return func(b *Box) {
+		// Ban durations must be positive and the maximum can't be shorter than the base duration
+		if base <= 0 || max < base {
+			return
+		}
+
+		b.config.BaseDuration = base
+		b.config.MaxDuration = max
+	}
+}
+
+func WithForgetAfter(duration time.Duration) Options {
+	return func(b *Box) {
+		if duration <= 0 {
+			return
+		}
+
+		b.config.ForgetAfter = duration
+	}
+}
+
+// WithObserver registers an observer, which is notified when a client is banned.
+func WithObserver(observer rate_limiter.Observer) Options {
+	return func(b *Box) {
+		if observer == nil {
+			return
+		}
+
+		b.observers = append(b.observers, observer)
+	}
+}
@@ -0,0 +1,38 @@
+package quota
+
+import "time"
+
+type Option func(*Tracker)
+
+// WithDefaultPlan sets the plan of the clients in tiers without a plan. Unlimited by default.
+func WithDefaultPlan(plan Plan) Option {
+	return func(t *Tracker) {
+		t.defaultPlan = plan
+	}
+}
+
+// WithPlan sets the plan of the clients in the tier.
+func WithPlan(tier string, plan Plan) Option {
+	return func(t *Tracker) {
+		t.plans[tier] = plan
+	}
+}
+
+// WithMaxClients sets the maximum number of tracked clients, which bounds the memory and the size of the store.
+// When the tracker is full, a new client replaces one of the least used clients.
+func WithMaxClients(maxClients int) Option {
+	return func(t *Tracker) {
+		if maxClients < 1 {
+			return
+		}
+
+		t.maxClients = maxClients
+	}
+}
+
+// WithLocation sets the time zone of the calendar days and months. Defaults to UTC.
+func WithLocation(location *time.Location) Option {
+	return func(t *Tracker) {
+		t.location = location
+	}
+}
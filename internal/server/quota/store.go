@@ -0,0 +1,98 @@
+package quota
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Record is the persisted usage of a client
+type Record struct {
+	// Tier of the client's last request
+	Tier string `json:"tier"`
+
+	// Day is the start of the day the daily usage was counted in
+	Day   time.Time `json:"day"`
+	Daily int       `json:"daily"`
+
+	// Month is the start of the month the monthly usage was counted in
+	Month   time.Time `json:"month"`
+	Monthly int       `json:"monthly"`
+}
+
+// Store persists the usage of the clients
+type Store interface {
+	Load() (map[string]Record, error)
+	Save(records map[string]Record) error
+}
+
+// FileStore persists the usage in a JSON file. The file is replaced atomically, so a crash never leaves a partially written file.
+type FileStore struct {
+	path string
+}
+
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+// Load reads the usage from the file. A missing file is an empty store.
+func (s *FileStore) Load() (map[string]Record, error) {
+	records := map[string]Record{}
+
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return records, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to read the quota usage")
+	}
+
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, errors.Wrap(err, "failed to parse the quota usage")
+	}
+
+	return records, nil
+}
+
+// Save writes the usage to a temporary file, syncs it to the disk and renames it over the previous file.
+func (s *FileStore) Save(records map[string]Record) error {
+	data, err := json.Marshal(records)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode the quota usage")
+	}
+
+	dir := filepath.Dir(s.path)
+	file, err := os.CreateTemp(dir, filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return errors.Wrap(err, "failed to create the quota usage file")
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := file.Write(data); err != nil {
+		_ = file.Close()
+		return errors.Wrap(err, "failed to write the quota usage")
+	}
+
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return errors.Wrap(err, "failed to sync the quota usage")
+	}
+
+	if err := file.Close(); err != nil {
+		return errors.Wrap(err, "failed to write the quota usage")
+	}
+
+	if err := os.Rename(file.Name(), s.path); err != nil {
+		return errors.Wrap(err, "failed to replace the quota usage")
+	}
+
+	// Persist the rename
+	if d, err := os.Open(dir); err == nil {
+		_ = d.Sync()
+		_ = d.Close()
+	}
+
+	return nil
+}
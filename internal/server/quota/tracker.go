@@ -0,0 +1,308 @@
+package quota
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Plan is the budget of requests per calendar day and month. Zero is unlimited.
+type Plan struct {
+	Daily   int
+	Monthly int
+}
+
+func (p Plan) unlimited() bool {
+	return p.Daily == 0 && p.Monthly == 0
+}
+
+// Counter is the usage of a budget
+type Counter struct {
+	Used int `json:"used"`
+
+	// Limit and Remaining are omitted if the budget is unlimited
+	Limit     int  `json:"limit,omitempty"`
+	Remaining *int `json:"remaining,omitempty"`
+
+	// ResetsAt is the start of the next day or month
+	ResetsAt time.Time `json:"resetsAt"`
+}
+
+func (c Counter) exhausted(n int) bool {
+	return c.Remaining != nil && *c.Remaining < n
+}
+
+// Usage is the consumption and the remaining budget of a client
+type Usage struct {
+	ClientID string  `json:"clientId"`
+	Tier     string  `json:"tier"`
+	Daily    Counter `json:"daily"`
+	Monthly  Counter `json:"monthly"`
+}
+
+// ResetsAt returns the time the exhausted budgets reset.
+func (u Usage) ResetsAt() time.Time {
+	if u.Monthly.exhausted(1) {
+		return u.Monthly.ResetsAt
+	}
+
+	return u.Daily.ResetsAt
+}
+
+// evictionSamples is the number of records sampled to find the least used client, when the tracker is full
+const evictionSamples = 16
+
+// Tracker accounts the requests of the clients against their daily and monthly budgets.
+// The budgets reset at the start of the calendar day and month in the tracker's time zone.
+// Only the clients with a limited plan are tracked, up to the maximum number of clients. When the tracker is full,
+// a new client replaces one of the least used clients, so the new clients are always charged.
+type Tracker struct {
+	store   Store
+	records map[string]Record
+
+	// maxClients is the maximum number of tracked clients
+	maxClients int
+
+	// dirty is set when the records changed since the last flush
+	dirty bool
+
+	defaultPlan Plan
+	plans       map[string]Plan
+	location    *time.Location
+
+	now    func() time.Time
+	mu     sync.Mutex
+	logger *zap.Logger
+}
+
+// NewTracker creates a tracker and loads the usage from the store.
+func NewTracker(store Store, opts ...Option) (*Tracker, error) {
+	t := &Tracker{
+		store:      store,
+		maxClients: 100000,
+		plans:      map[string]Plan{},
+		location:   time.UTC,
+		now:        time.Now,
+		logger:     zap.L().Named("quota"),
+	}
+
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	records, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+	t.records = records
+
+	return t, nil
+}
+
+// SetPlans replaces the default plan and the plans of the tiers. The usage of the clients is kept.
+func (t *Tracker) SetPlans(defaultPlan Plan, plans map[string]Plan) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.defaultPlan = defaultPlan
+	t.plans = plans
+}
+
+// Consume charges n units to the client's budgets. The request is only charged if both budgets have enough units left.
+// The clients with an unlimited plan are not charged.
+func (t *Tracker) Consume(clientID, tier string, n int) (Usage, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	existing, exists := t.records[clientID]
+	record := t.current(existing, now)
+	record.Tier = tier
+
+	usage := t.usageOf(clientID, record, now)
+	if t.planOf(tier).unlimited() {
+		return usage, true
+	}
+
+	if usage.Daily.exhausted(n) || usage.Monthly.exhausted(n) {
+		return usage, false
+	}
+
+	if !exists && len(t.records) >= t.maxClients {
+		t.evictLeastUsed(now)
+	}
+
+	record.Daily += n
+	record.Monthly += n
+	t.records[clientID] = record
+	t.dirty = true
+
+	return t.usageOf(clientID, record, now), true
+}
+
+// Usage returns the usage of the client.
+func (t *Tracker) Usage(clientID string) (Usage, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	record, exists := t.records[clientID]
+	if !exists {
+		return Usage{}, false
+	}
+
+	now := t.now()
+	return t.usageOf(clientID, t.current(record, now), now), true
+}
+
+// Usages returns the usage of all the clients, sorted by the client ID.
+func (t *Tracker) Usages() []Usage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	usages := make([]Usage, 0, len(t.records))
+	for clientID, record := range t.records {
+		usages = append(usages, t.usageOf(clientID, t.current(record, now), now))
+	}
+
+	sort.Slice(usages, func(i, j int) bool {
+		return usages[i].ClientID < usages[j].ClientID
+	})
+
+	return usages
+}
+
+// Flush saves the usage to the store, if it changed. The records without usage in the current month are removed,
+// which makes room for the new clients.
+func (t *Tracker) Flush() error {
+	t.mu.Lock()
+	month := monthStart(t.now().In(t.location))
+	for clientID, record := range t.records {
+		if record.Month.Before(month) || record.Monthly == 0 {
+			delete(t.records, clientID)
+			t.dirty = true
+		}
+	}
+
+	if !t.dirty {
+		t.mu.Unlock()
+		return nil
+	}
+
+	records := make(map[string]Record, len(t.records))
+	for clientID, record := range t.records {
+		records[clientID] = record
+	}
+	t.dirty = false
+	t.mu.Unlock()
+
+	if err := t.store.Save(records); err != nil {
+		t.mu.Lock()
+		t.dirty = true
+		t.mu.Unlock()
+		return err
+	}
+
+	return nil
+}
+
+// Run flushes the usage periodically until the context is cancelled, after which the usage is flushed for the last time.
+func (t *Tracker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := t.Flush(); err != nil {
+				t.logger.Error("Failed to persist the quota usage", zap.Error(err))
+			}
+			return
+		case <-ticker.C:
+			if err := t.Flush(); err != nil {
+				t.logger.Warn("Failed to persist the quota usage", zap.Error(err))
+			}
+		}
+	}
+}
+
+// current resets the counters of the record if the day or the month changed. Must be called with the lock held.
+func (t *Tracker) current(record Record, now time.Time) Record {
+	now = now.In(t.location)
+
+	if day := dayStart(now); !record.Day.Equal(day) {
+		record.Day = day
+		record.Daily = 0
+	}
+
+	if month := monthStart(now); !record.Month.Equal(month) {
+		record.Month = month
+		record.Monthly = 0
+	}
+
+	return record
+}
+
+// evictLeastUsed removes the client with the lowest monthly usage among a sample of the records. The map iteration
+// order is random, so the sample differs on every call. Must be called with the lock held.
+func (t *Tracker) evictLeastUsed(now time.Time) {
+	leastUsed, lowest, sampled := "", 0, 0
+	for clientID, record := range t.records {
+		monthly := t.current(record, now).Monthly
+		if sampled == 0 || monthly < lowest {
+			leastUsed, lowest = clientID, monthly
+		}
+
+		sampled++
+		if sampled == evictionSamples {
+			break
+		}
+	}
+
+	delete(t.records, leastUsed)
+}
+
+// planOf returns the plan of the tier. Must be called with the lock held.
+func (t *Tracker) planOf(tier string) Plan {
+	plan, exists := t.plans[tier]
+	if !exists {
+		return t.defaultPlan
+	}
+
+	return plan
+}
+
+// usageOf reports the usage of the record against the plan of the client's tier. Must be called with the lock held.
+func (t *Tracker) usageOf(clientID string, record Record, now time.Time) Usage {
+	plan := t.planOf(record.Tier)
+
+	now = now.In(t.location)
+	return Usage{
+		ClientID: clientID,
+		Tier:     record.Tier,
+		Daily:    counter(record.Daily, plan.Daily, dayStart(now).AddDate(0, 0, 1)),
+		Monthly:  counter(record.Monthly, plan.Monthly, monthStart(now).AddDate(0, 1, 0)),
+	}
+}
+
+func counter(used, limit int, resetsAt time.Time) Counter {
+	c := Counter{Used: used, ResetsAt: resetsAt}
+	if limit > 0 {
+		remaining := max(limit-used, 0)
+		c.Limit = limit
+		c.Remaining = &remaining
+	}
+
+	return c
+}
+
+func dayStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+func monthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,153 @@
+package quota
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTracker_Consume(t *testing.T) {
+	now := time.Date(2024, 1, 31, 23, 0, 0, 0, time.UTC)
+	tracker, err := NewTracker(
+		NewFileStore(filepath.Join(t.TempDir(), "quotas.json")),
+		WithDefaultPlan(Plan{Daily: 3, Monthly: 5}),
+		WithPlan("premium", Plan{Monthly: 100}),
+	)
+	assert.NoError(t, err)
+	tracker.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		_, ok := tracker.Consume("1", "default", 1)
+		assert.True(t, ok)
+	}
+
+	// The daily budget is exhausted
+	usage, ok := tracker.Consume("1", "default", 1)
+	assert.False(t, ok)
+	assert.Equal(t, 3, usage.Daily.Used)
+	assert.Equal(t, 0, *usage.Daily.Remaining)
+	assert.Equal(t, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), usage.ResetsAt())
+
+	// Premium clients have no daily budget
+	usage, ok = tracker.Consume("2", "premium", 10)
+	assert.True(t, ok)
+	assert.Nil(t, usage.Daily.Remaining)
+	assert.Equal(t, 90, *usage.Monthly.Remaining)
+
+	// The budgets reset at the start of the month
+	now = now.Add(2 * time.Hour)
+	usage, ok = tracker.Consume("1", "default", 2)
+	assert.True(t, ok)
+	assert.Equal(t, 2, usage.Monthly.Used)
+	assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), usage.Monthly.ResetsAt)
+
+	// The request is rejected if it costs more than the remaining budget, without being charged
+	_, ok = tracker.Consume("1", "default", 2)
+	assert.False(t, ok)
+	usage, _ = tracker.Usage("1")
+	assert.Equal(t, 2, usage.Daily.Used)
+}
+
+func TestTracker_MonthlyBudget(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tracker, err := NewTracker(NewFileStore(filepath.Join(t.TempDir(), "quotas.json")), WithDefaultPlan(Plan{Daily: 2, Monthly: 3}))
+	assert.NoError(t, err)
+	tracker.now = func() time.Time { return now }
+
+	for day := 0; day < 2; day++ {
+		for i := 0; i < 2; i++ {
+			_, ok := tracker.Consume("1", "default", 1)
+			assert.Equal(t, day == 0 || i == 0, ok)
+		}
+
+		now = now.AddDate(0, 0, 1)
+	}
+
+	usage, _ := tracker.Usage("1")
+	assert.Equal(t, 3, usage.Monthly.Used)
+	assert.Equal(t, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), usage.ResetsAt())
+}
+
+func TestTracker_Persistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quotas.json")
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+
+	tracker, err := NewTracker(NewFileStore(path), WithDefaultPlan(Plan{Monthly: 10}))
+	assert.NoError(t, err)
+	tracker.now = func() time.Time { return now }
+
+	_, _ = tracker.Consume("1", "default", 4)
+	_, _ = tracker.Consume("2", "default", 1)
+
+	// The usage is flushed when the tracker stops
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	tracker.Run(ctx, time.Minute)
+
+	restored, err := NewTracker(NewFileStore(path), WithDefaultPlan(Plan{Monthly: 10}))
+	assert.NoError(t, err)
+	restored.now = func() time.Time { return now }
+
+	usages := restored.Usages()
+	assert.Len(t, usages, 2)
+	assert.Equal(t, 4, usages[0].Monthly.Used)
+	assert.Equal(t, 6, *usages[0].Monthly.Remaining)
+
+	// The records of the previous months are removed
+	now = now.AddDate(0, 1, 0)
+	_, _ = restored.Consume("2", "default", 1)
+	assert.NoError(t, restored.Flush())
+
+	records, err := NewFileStore(path).Load()
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+	assert.Contains(t, records, "2")
+}
+
+func TestTracker_BoundedClients(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quotas.json")
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	tracker, err := NewTracker(
+		NewFileStore(path),
+		WithDefaultPlan(Plan{Monthly: 3}),
+		WithPlan("unlimited", Plan{}),
+		WithMaxClients(2),
+	)
+	assert.NoError(t, err)
+	tracker.now = func() time.Time { return now }
+
+	// The clients with an unlimited plan are not tracked
+	for i := 0; i < 3; i++ {
+		_, ok := tracker.Consume("unlimited", "unlimited", 1)
+		assert.True(t, ok)
+	}
+	assert.Empty(t, tracker.Usages())
+
+	// Fill the tracker
+	_, _ = tracker.Consume("1", "default", 2)
+	_, _ = tracker.Consume("2", "default", 1)
+
+	// A new client replaces the least used client and is still limited
+	for i := 0; i < 3; i++ {
+		_, ok := tracker.Consume("3", "default", 1)
+		assert.True(t, ok)
+	}
+	_, ok := tracker.Consume("3", "default", 1)
+	assert.False(t, ok)
+
+	_, exists := tracker.Usage("2")
+	assert.False(t, exists)
+	assert.Len(t, tracker.Usages(), 2)
+
+	// The records of the previous months are removed on flush
+	now = now.AddDate(0, 1, 0)
+	assert.NoError(t, tracker.Flush())
+	assert.Empty(t, tracker.Usages())
+
+	records, err := NewFileStore(path).Load()
+	assert.NoError(t, err)
+	assert.Empty(t, records)
+}
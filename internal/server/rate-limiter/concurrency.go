@@ -0,0 +1,72 @@
+package rate_limiter
+
+import (
+	"sync"
+)
+
+// ConcurrencyLimiter limits the number of requests a client can have in flight at once.
+type ConcurrencyLimiter struct {
+	limit int
+
+	// inFlight is a map of client IDs to their number of requests in flight. Clients without requests in flight are removed.
+	inFlight map[string]int
+
+	mu sync.Mutex
+}
+
+// NewConcurrencyLimiter creates a limiter allowing each client the limit of requests in flight.
+// A limit of zero or less means the clients have no limit.
+func NewConcurrencyLimiter(limit int) *ConcurrencyLimiter {
+	return &ConcurrencyLimiter{
+		limit:    limit,
+		inFlight: make(map[string]int),
+	}
+}
+
+// Acquire acquires a slot for the client's request. The release function must be called when the request completes,
+// preferably deferred, so the slot is released on panics as well. Calling it more than once has no effect.
+func (l *ConcurrencyLimiter) Acquire(clientID string) (release func(), ok bool) {
+	if l.limit <= 0 {
+		return func() {}, true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.inFlight[clientID] >= l.limit {
+		return func() {}, false
+	}
+
+	l.inFlight[clientID]++
+
+	once := sync.Once{}
+	return func() {
+		once.Do(func() { l.release(clientID) })
+	}, true
+}
+
+func (l *ConcurrencyLimiter) release(clientID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.inFlight[clientID]--
+	if l.inFlight[clientID] <= 0 {
+		delete(l.inFlight, clientID)
+	}
+}
+
+// InFlight returns the number of the client's requests in flight.
+func (l *ConcurrencyLimiter) InFlight(clientID string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.inFlight[clientID]
+}
+
+// TrackedClients returns the number of clients with requests in flight.
+func (l *ConcurrencyLimiter) TrackedClients() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return len(l.inFlight)
+}
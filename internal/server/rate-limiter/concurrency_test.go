@@ -0,0 +1,73 @@
+package rate_limiter
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConcurrencyLimiter(t *testing.T) {
+	limiter := NewConcurrencyLimiter(2)
+
+	release1, ok := limiter.Acquire("1")
+	assert.True(t, ok)
+	release2, ok := limiter.Acquire("1")
+	assert.True(t, ok)
+	_, ok = limiter.Acquire("1")
+	assert.False(t, ok)
+
+	// Other clients have their own slots
+	release3, ok := limiter.Acquire("2")
+	assert.True(t, ok)
+	assert.Equal(t, 2, limiter.TrackedClients())
+
+	// Releasing more than once has no effect
+	release1()
+	release1()
+	assert.Equal(t, 1, limiter.InFlight("1"))
+
+	_, ok = limiter.Acquire("1")
+	assert.True(t, ok)
+	_, ok = limiter.Acquire("1")
+	assert.False(t, ok)
+
+	release2()
+	release3()
+	assert.Equal(t, 0, limiter.InFlight("2"))
+	assert.Equal(t, 1, limiter.TrackedClients())
+}
+
+func TestConcurrencyLimiter_Unlimited(t *testing.T) {
+	limiter := NewConcurrencyLimiter(0)
+
+	for i := 0; i < 10; i++ {
+		release, ok := limiter.Acquire("1")
+		assert.True(t, ok)
+		defer release()
+	}
+
+	assert.Equal(t, 0, limiter.TrackedClients())
+}
+
+func TestConcurrencyLimiter_Concurrent(t *testing.T) {
+	limiter := NewConcurrencyLimiter(5)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			release, ok := limiter.Acquire("1")
+			if ok {
+				assert.LessOrEqual(t, limiter.InFlight("1"), 5)
+				release()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 0, limiter.InFlight("1"))
+	assert.Equal(t, 0, limiter.TrackedClients())
+}
@@ -0,0 +1,205 @@
+package rate_limiter
+
+import (
+	"hash/maphash"
+	"math"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// sketch is a count-min sketch with conservative updates
+type sketch struct {
+	width    uint64
+	depth    int
+	counters []uint32
+
+	// start of the window the sketch counts the requests for
+	start time.Time
+}
+
+func newSketch(width, depth int) *sketch {
+	return &sketch{
+		width:    uint64(width),
+		depth:    depth,
+		counters: make([]uint32, width*depth),
+	}
+}
+
+// indexes calculates the counter of the key in every row using double hashing
+func (s *sketch) indexes(hash uint64, indexes []uint64) {
+	h1, h2 := hash&math.MaxUint32, hash>>32
+	for row := 0; row < s.depth; row++ {
+		indexes[row] = uint64(row)*s.width + (h1+uint64(row)*h2)%s.width
+	}
+}
+
+func (s *sketch) estimate(indexes []uint64) uint32 {
+	estimate := uint32(math.MaxUint32)
+	for _, index := range indexes {
+		estimate = min(estimate, s.counters[index])
+	}
+
+	return estimate
+}
+
+// add raises only the counters below the new estimate (conservative update), which reduces the overestimation
+func (s *sketch) add(indexes []uint64, n uint32) {
+	target := s.estimate(indexes)
+	target += min(n, math.MaxUint32-target)
+	for _, index := range indexes {
+		s.counters[index] = max(s.counters[index], target)
+	}
+}
+
+func (s *sketch) reset(start time.Time) {
+	clear(s.counters)
+	s.start = start
+}
+
+// CountMinRateLimiter is an approximate rate limiter for huge key spaces (e.g. per IP and path). The requests are counted
+// in count-min sketches, so the memory is fixed regardless of the number of clients.
+//
+// The windows are aligned to the clock and rotated: the count of a client is the count of the current window, plus the
+// count of the previous window weighted by the part of the previous window still covered by the sliding window.
+//
+// Error bound: a sketch of width w and depth d overestimates the stored count of a client by at most e/w * N (where N is
+// the total number of requests in the window) with a probability of at least 1 - e^-d. The stored counts are never
+// underestimated, so the collisions can only limit clients within the limit. The sliding window itself is approximate:
+// the previous window is weighted as if its requests were spread evenly, and the limited requests are not counted, so
+// a client can get somewhat more or fewer requests through than the limit within any exact sliding window.
+// Use SketchSize to size the sketch for the desired error bound.
+type CountMinRateLimiter struct {
+	config Config
+	width  int
+	depth  int
+
+	// windows are shared with the reconfigured limiters
+	windows *windows
+
+	seed maphash.Seed
+
+	// observers are notified about the limiter decisions
+	observers []Observer
+
+	logger *zap.Logger
+	now    func() time.Time
+}
+
+// windows are the sketches of the current and the previous window
+type windows struct {
+	current  *sketch
+	previous *sketch
+
+	mu sync.Mutex
+}
+
+// SketchSize calculates the width and the depth of the sketch, so that the count is overestimated by at most
+// epsilon * N with the probability of 1 - delta.
+func SketchSize(epsilon, delta float64) (width, depth int) {
+	width = int(math.Ceil(math.E / epsilon))
+	depth = int(math.Ceil(math.Log(1 / delta)))
+	return max(width, 1), max(depth, 1)
+}
+
+// NewCountMinRateLimiter creates a new count-min sketch rate limiter with the provided configuration and sketch size.
+// The memory used is 8 * width * depth bytes.
+func NewCountMinRateLimiter(config Config, width, depth int, observers ...Observer) *CountMinRateLimiter {
+	width = max(width, 1)
+	depth = max(depth, 1)
+
+	return &CountMinRateLimiter{
+		config:    config,
+		width:     width,
+		depth:     depth,
+		windows:   &windows{current: newSketch(width, depth), previous: newSketch(width, depth)},
+		seed:      maphash.MakeSeed(),
+		observers: observers,
+		logger:    zap.L().Named("count-min-rate-limiter"),
+		now:       time.Now,
+	}
+}
+
+// Size returns the width and the depth of the sketches
+func (l *CountMinRateLimiter) Size() (width, depth int) {
+	return l.width, l.depth
+}
+
+// Reconfigured returns a limiter with the configuration, which shares the sketches with this limiter. The limits of
+// this limiter don't change, so the requests it still evaluates keep the previous limits until the reconfigured limiter replaces it.
+func (l *CountMinRateLimiter) Reconfigured(config Config) *CountMinRateLimiter {
+	l.logger.Info("Updating the rate limiter configuration", zap.Int("limit", config.Limit), zap.Duration("duration", config.Duration))
+
+	reconfigured := *l
+	reconfigured.config = config
+	return &reconfigured
+}
+
+// rotate rotates the sketches if the current window has ended. Must be called with the lock held.
+func (l *CountMinRateLimiter) rotate(now time.Time) {
+	w := l.windows
+	start := now.Truncate(l.config.Duration)
+	if !start.After(w.current.start) {
+		return
+	}
+
+	// The current window becomes the previous one, unless it is older than a single window
+	w.current, w.previous = w.previous, w.current
+	if !w.previous.start.Equal(start.Add(-l.config.Duration)) {
+		w.previous.reset(start.Add(-l.config.Duration))
+	}
+
+	w.current.reset(start)
+}
+
+func (l *CountMinRateLimiter) IsLimited(clientID string) bool {
+	return l.IsLimitedN(clientID, 1, 0)
+}
+
+// IsLimitedN checks a request costing n units. A positive limit replaces the configured limit.
+func (l *CountMinRateLimiter) IsLimitedN(clientID string, n, requestLimit int) bool {
+	hash := maphash.String(l.seed, clientID)
+	indexes := make([]uint64, l.depth)
+
+	w := l.windows
+	w.mu.Lock()
+	now := l.now()
+	l.rotate(now)
+	w.current.indexes(hash, indexes)
+
+	// Weight the previous window by the part still covered by the sliding window
+	elapsed := float64(now.Sub(w.current.start)) / float64(l.config.Duration)
+	estimate := float64(w.current.estimate(indexes)) + float64(w.previous.estimate(indexes))*(1-elapsed)
+
+	limit := l.config.Limit
+	if requestLimit > 0 {
+		limit = requestLimit
+	}
+
+	limited := estimate+float64(n-1) >= float64(limit)
+	if !limited {
+		w.current.add(indexes, uint32(n))
+	}
+	w.mu.Unlock()
+
+	if len(l.observers) > 0 {
+		event := Event{
+			Type:        EventRequestAllowed,
+			ClientID:    clientID,
+			Time:        now,
+			Count:       int(math.Ceil(estimate)) + n,
+			Limit:       limit,
+			WindowStart: now.Truncate(l.config.Duration),
+		}
+		if limited {
+			event.Type = EventRequestLimited
+		}
+
+		for _, observer := range l.observers {
+			observer.OnEvent(event)
+		}
+	}
+
+	return limited
+}
@@ -0,0 +1,115 @@
+package rate_limiter
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestSketchSize(t *testing.T) {
+	width, depth := SketchSize(0.001, 0.01)
+	assert.Equal(t, 2719, width)
+	assert.Equal(t, 5, depth)
+}
+
+func TestCountMinRateLimiter(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	zap.ReplaceGlobals(logger)
+
+	now := time.Now().Truncate(time.Second)
+	rateLimiter := NewCountMinRateLimiter(Config{Limit: 5, Duration: time.Second}, 1000, 4)
+	rateLimiter.now = func() time.Time { return now }
+
+	for i := 0; i < 5; i++ {
+		assert.False(t, rateLimiter.IsLimited("1"))
+	}
+	assert.True(t, rateLimiter.IsLimited("1"))
+	assert.False(t, rateLimiter.IsLimited("2"))
+
+	// Halfway through the next window, half of the previous window is still counted
+	now = now.Add(1500 * time.Millisecond)
+	for i := 0; i < 3; i++ {
+		assert.False(t, rateLimiter.IsLimited("1"))
+	}
+	assert.True(t, rateLimiter.IsLimited("1"))
+
+	// After two windows, the previous windows are forgotten
+	now = now.Add(2 * time.Second)
+	for i := 0; i < 5; i++ {
+		assert.False(t, rateLimiter.IsLimited("1"))
+	}
+	assert.True(t, rateLimiter.IsLimited("1"))
+}
+
+func TestCountMinRateLimiter_Reconfigured(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	zap.ReplaceGlobals(logger)
+
+	now := time.Now().Truncate(time.Second)
+	rateLimiter := NewCountMinRateLimiter(Config{Limit: 2, Duration: time.Second}, 1000, 4)
+	rateLimiter.now = func() time.Time { return now }
+	assert.False(t, rateLimiter.IsLimited("1"))
+	assert.False(t, rateLimiter.IsLimited("1"))
+
+	// The reconfigured limiter shares the sketches, while the previous limiter keeps its limits
+	reconfigured := rateLimiter.Reconfigured(Config{Limit: 3, Duration: time.Second})
+	assert.True(t, rateLimiter.IsLimited("1"))
+	assert.False(t, reconfigured.IsLimited("1"))
+	assert.True(t, reconfigured.IsLimited("1"))
+}
+
+func TestCountMinRateLimiter_RandomClients(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	zap.ReplaceGlobals(logger)
+
+	// Clients with random IDs (e.g. an attack) don't grow the memory and only slightly affect the other clients
+	width, depth := SketchSize(0.0001, 0.01)
+	rateLimiter := NewCountMinRateLimiter(Config{Limit: 100, Duration: time.Minute}, width, depth)
+	for i := 0; i < 100000; i++ {
+		rateLimiter.IsLimited(fmt.Sprintf("random-%d", i))
+	}
+
+	allowed := 0
+	for i := 0; i < 100; i++ {
+		if !rateLimiter.IsLimited("1") {
+			allowed++
+		}
+	}
+
+	// The overestimation is at most 0.01% of the 100000 requests
+	assert.GreaterOrEqual(t, allowed, 90)
+	assert.True(t, rateLimiter.IsLimited("1"))
+}
+
+func TestCountMinRateLimiter_Observer(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	zap.ReplaceGlobals(logger)
+
+	observer := &recordingObserver{}
+	rateLimiter := NewCountMinRateLimiter(Config{Limit: 1, Duration: time.Minute}, 100, 2, observer)
+
+	rateLimiter.IsLimited("1")
+	rateLimiter.IsLimited("1")
+	assert.Equal(t, []EventType{EventRequestAllowed, EventRequestLimited}, observer.types())
+}
+
+func TestCountMinRateLimiter_IsLimitedN(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	zap.ReplaceGlobals(logger)
+
+	now := time.Now().Truncate(time.Second)
+	rateLimiter := NewCountMinRateLimiter(Config{Limit: 10, Duration: time.Second}, 1000, 4)
+	rateLimiter.now = func() time.Time { return now }
+
+	assert.False(t, rateLimiter.IsLimitedN("1", 5, 0))
+	assert.False(t, rateLimiter.IsLimitedN("1", 5, 0))
+	assert.True(t, rateLimiter.IsLimitedN("1", 1, 0))
+
+	// The limit of the request replaces the configured limit
+	assert.False(t, rateLimiter.IsLimitedN("2", 15, 20))
+	assert.True(t, rateLimiter.IsLimitedN("2", 6, 20))
+	assert.False(t, rateLimiter.IsLimitedN("2", 5, 20))
+}
@@ -0,0 +1,161 @@
+package rate_limiter
+
+import (
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ClientState is the state of a client tracked by the limiter
+type ClientState struct {
+	ClientID    string    `json:"clientId"`
+	Count       int       `json:"count"`
+	Limit       int       `json:"limit"`
+	Remaining   int       `json:"remaining"`
+	WindowStart time.Time `json:"windowStart"`
+	WindowEnd   time.Time `json:"windowEnd"`
+	Override    *Override `json:"override,omitempty"`
+}
+
+// Override is a temporary limit of a client
+type Override struct {
+	Limit int       `json:"limit"`
+	Until time.Time `json:"until"`
+}
+
+// Inspector exposes and manipulates the per-client state of a limiter
+type Inspector interface {
+	// Clients returns the state of all tracked clients, ordered by their ID
+	Clients() []ClientState
+
+	// Client returns the state of a single client
+	Client(clientID string) (ClientState, bool)
+
+	// Reset resets the client's window
+	Reset(clientID string) bool
+
+	// SetOverride sets a temporary limit for the client
+	SetOverride(clientID string, limit int, ttl time.Duration)
+
+	// RemoveOverride removes the client's temporary limit
+	RemoveOverride(clientID string) bool
+}
+
+// limitOf returns the limit of the client's window, taking the overrides into account. Must be called with the lock held.
+func (l *SlidingWindowRateLimiter) limitOf(userID string, userLimits clientLimit, now time.Time) int {
+	override, exists := l.overrides[userID]
+	if exists && now.Before(override.Until) {
+		return override.Limit
+	}
+
+	return l.windowConfig(userLimits).Limit
+}
+
+// limitFor returns the limit of the client's request. The override of the client takes precedence over the limit of the request.
+// Must be called with the lock held.
+func (l *SlidingWindowRateLimiter) limitFor(userID string, userLimits clientLimit, requestLimit int, now time.Time) int {
+	override, exists := l.overrides[userID]
+	if requestLimit > 0 && !(exists && now.Before(override.Until)) {
+		return requestLimit
+	}
+
+	return l.limitOf(userID, userLimits, now)
+}
+
+// stateOf returns the state of the client. Must be called with the lock held.
+func (l *SlidingWindowRateLimiter) stateOf(userID string, userLimits clientLimit, now time.Time) ClientState {
+	state := ClientState{
+		ClientID:    userID,
+		Count:       userLimits.requestCount,
+		Limit:       l.limitOf(userID, userLimits, now),
+		WindowStart: *userLimits.windowStart,
+		WindowEnd:   userLimits.windowStart.Add(l.windowConfig(userLimits).Duration),
+	}
+	state.Remaining = max(state.Limit-state.Count, 0)
+
+	if override, exists := l.overrides[userID]; exists && now.Before(override.Until) {
+		state.Override = &override
+	}
+
+	return state
+}
+
+// TrackedClients returns the number of clients tracked by the limiter
+func (l *SlidingWindowRateLimiter) TrackedClients() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return len(l.userLimits)
+}
+
+func (l *SlidingWindowRateLimiter) Clients() []ClientState {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	now := time.Now()
+	clients := make([]ClientState, 0, len(l.userLimits))
+	for userID, userLimits := range l.userLimits {
+		clients = append(clients, l.stateOf(userID, userLimits, now))
+	}
+
+	sort.Slice(clients, func(i, j int) bool {
+		return clients[i].ClientID < clients[j].ClientID
+	})
+
+	return clients
+}
+
+func (l *SlidingWindowRateLimiter) Client(clientID string) (ClientState, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	userLimits, exists := l.userLimits[clientID]
+	if !exists {
+		return ClientState{}, false
+	}
+
+	return l.stateOf(clientID, userLimits, time.Now()), true
+}
+
+func (l *SlidingWindowRateLimiter) Reset(clientID string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	_, exists := l.userLimits[clientID]
+	delete(l.userLimits, clientID)
+
+	l.logger.Info("Client window reset", zap.String("clientId", clientID))
+	return exists
+}
+
+func (l *SlidingWindowRateLimiter) SetOverride(clientID string, limit int, ttl time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+
+	// Drop the expired overrides
+	for userID, override := range l.overrides {
+		if !now.Before(override.Until) {
+			delete(l.overrides, userID)
+		}
+	}
+
+	l.overrides[clientID] = Override{
+		Limit: limit,
+		Until: now.Add(ttl),
+	}
+
+	l.logger.Info("Client limit overridden", zap.String("clientId", clientID), zap.Int("limit", limit), zap.Duration("ttl", ttl))
+}
+
+func (l *SlidingWindowRateLimiter) RemoveOverride(clientID string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	override, exists := l.overrides[clientID]
+	delete(l.overrides, clientID)
+
+	return exists && time.Now().Before(override.Until)
+}
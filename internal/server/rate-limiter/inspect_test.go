@@ -0,0 +1,70 @@
+package rate_limiter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestSlidingWindowRateLimiter_Inspect(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	zap.ReplaceGlobals(logger)
+
+	rateLimiter := NewSlidingWindowRateLimiter(WithLimit(3))
+	assert.Empty(t, rateLimiter.Clients())
+
+	rateLimiter.IsLimited("2")
+	rateLimiter.IsLimited("1")
+	rateLimiter.IsLimited("1")
+
+	clients := rateLimiter.Clients()
+	assert.Len(t, clients, 2)
+	assert.Equal(t, 2, rateLimiter.TrackedClients())
+	assert.Equal(t, "1", clients[0].ClientID)
+	assert.Equal(t, 2, clients[0].Count)
+	assert.Equal(t, 1, clients[0].Remaining)
+	assert.Equal(t, clients[0].WindowStart.Add(5*time.Second), clients[0].WindowEnd)
+
+	_, exists := rateLimiter.Client("3")
+	assert.False(t, exists)
+
+	// Reset the client's window
+	assert.True(t, rateLimiter.Reset("1"))
+	_, exists = rateLimiter.Client("1")
+	assert.False(t, exists)
+	assert.False(t, rateLimiter.Reset("1"))
+}
+
+func TestSlidingWindowRateLimiter_Override(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	zap.ReplaceGlobals(logger)
+
+	rateLimiter := NewSlidingWindowRateLimiter(WithLimit(1))
+	rateLimiter.SetOverride("1", 2, time.Minute)
+
+	assert.False(t, rateLimiter.IsLimited("1"))
+	assert.False(t, rateLimiter.IsLimited("1"))
+	assert.True(t, rateLimiter.IsLimited("1"))
+
+	state, exists := rateLimiter.Client("1")
+	assert.True(t, exists)
+	assert.Equal(t, 2, state.Limit)
+	assert.Equal(t, 0, state.Remaining)
+	assert.NotNil(t, state.Override)
+
+	// Other clients keep the configured limit
+	assert.False(t, rateLimiter.IsLimited("2"))
+	assert.True(t, rateLimiter.IsLimited("2"))
+
+	// Expired overrides are ignored
+	rateLimiter.SetOverride("2", 5, -time.Second)
+	assert.True(t, rateLimiter.IsLimited("2"))
+	assert.False(t, rateLimiter.RemoveOverride("2"))
+
+	assert.True(t, rateLimiter.RemoveOverride("1"))
+	state, _ = rateLimiter.Client("1")
+	assert.Equal(t, 1, state.Limit)
+	assert.Nil(t, state.Override)
+}
@@ -7,6 +7,29 @@ import (
 	"go.uber.org/zap"
 )
 
+// Limiter decides whether a client has exceeded its rate limit
+type Limiter interface {
+	IsLimited(clientID string) bool
+}
+
+// WeightedLimiter limits the clients by the total cost of their requests
+type WeightedLimiter interface {
+	Limiter
+
+	// IsLimitedN checks a request costing n units. A positive limit replaces the configured limit of the client.
+	IsLimitedN(clientID string, n, limit int) bool
+}
+
+// IsLimitedN checks a request costing n units with the limit, if the limiter supports weighted requests.
+// Otherwise, the cost and the limit are ignored.
+func IsLimitedN(limiter Limiter, clientID string, n, limit int) bool {
+	if weighted, ok := limiter.(WeightedLimiter); ok {
+		return weighted.IsLimitedN(clientID, n, limit)
+	}
+
+	return limiter.IsLimited(clientID)
+}
+
 // Configuration for the rate limiter
 type Config struct {
 
@@ -15,6 +38,10 @@ type Config struct {
 
 	// Duration is the duration of the window
 	Duration time.Duration
+
+	// Aligned aligns the windows to the wall clock (e.g. every minute or midnight UTC) instead of the client's first request.
+	// The windows reset at the same time for all the clients and replicas.
+	Aligned bool
 }
 
 type clientLimit struct {
@@ -23,6 +50,9 @@ type clientLimit struct {
 
 	// Time when the current window started
 	windowStart *time.Time
+
+	// period of the schedule active when the window opened. The window keeps the limits of the period until it expires.
+	period string
 }
 
 // SlidingWindowRateLimiter is a sliding window rate limiter that limits the number of requests per user for a given time window.
@@ -33,7 +63,17 @@ type SlidingWindowRateLimiter struct {
 	// userLimits is a map of user IDs to their current request count and the time the window started
 	userLimits map[string]clientLimit
 
-	mu     sync.RWMutex
+	// overrides is a map of user IDs to their temporary limits
+	overrides map[string]Override
+
+	// observers are notified about the limiter events
+	observers []Observer
+
+	// schedule replaces the limits of the windows opened during its periods
+	schedule Schedule
+
+	// mu guards the state of the clients, which is shared with the reconfigured limiters
+	mu     *sync.RWMutex
 	logger *zap.Logger
 }
 
@@ -45,6 +85,8 @@ func NewSlidingWindowRateLimiter(opts ...Options) *SlidingWindowRateLimiter {
 			Duration: time.Second * 5,
 		},
 		userLimits: make(map[string]clientLimit),
+		overrides:  make(map[string]Override),
+		mu:         &sync.RWMutex{},
 		logger:     zap.L().Named("rate-limiter"),
 	}
 
@@ -57,68 +99,163 @@ func NewSlidingWindowRateLimiter(opts ...Options) *SlidingWindowRateLimiter {
 }
 
 // NewSlidingWindowRateLimiterFromConfig creates a new sliding window rate limiter with the provided configuration
-func NewSlidingWindowRateLimiterFromConfig(config Config) *SlidingWindowRateLimiter {
-	return &SlidingWindowRateLimiter{
+func NewSlidingWindowRateLimiterFromConfig(config Config, opts ...Options) *SlidingWindowRateLimiter {
+	limiter := &SlidingWindowRateLimiter{
 		config:     config,
 		userLimits: make(map[string]clientLimit),
+		overrides:  make(map[string]Override),
+		mu:         &sync.RWMutex{},
 		logger:     zap.L().Named("rate-limiter"),
 	}
+
+	// Apply options
+	for _, opt := range opts {
+		opt(limiter)
+	}
+
+	return limiter
 }
 
-func (l *SlidingWindowRateLimiter) incrementRequestCount(userID string) {
-	l.logger.Debug("Incrementing the number request")
+// Config returns the current configuration of the rate limiter
+func (l *SlidingWindowRateLimiter) Config() Config {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
 
-	// Get the current user rate limit
-	userLimits, exists := l.userLimits[userID]
-	if !exists {
-		// If the request limit does not exist, create a new entry
-		currentTime := time.Now()
-		l.userLimits[userID] = clientLimit{
-			requestCount: 0,
-			windowStart:  &currentTime,
-		}
+	return l.config
+}
 
-		userLimits = l.userLimits[userID]
-	}
+// SetConfig replaces the configuration of the rate limiter. The request counts of the clients are kept.
+func (l *SlidingWindowRateLimiter) SetConfig(config Config) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 
-	// Increment the request count and update
-	userLimits.requestCount++
-	l.userLimits[userID] = userLimits
+	l.logger.Info("Updating the rate limiter configuration", zap.Int("limit", config.Limit), zap.Duration("duration", config.Duration))
+	l.config = config
+}
+
+// Reconfigured returns a limiter with the configuration and the schedule, which shares the request counts and the
+// overrides of the clients with this limiter. The limits of this limiter don't change, so the requests it still
+// evaluates keep the previous limits until the reconfigured limiter replaces it.
+func (l *SlidingWindowRateLimiter) Reconfigured(config Config, schedule Schedule) *SlidingWindowRateLimiter {
+	l.logger.Info("Updating the rate limiter configuration", zap.Int("limit", config.Limit), zap.Duration("duration", config.Duration))
+
+	return &SlidingWindowRateLimiter{
+		config:     config,
+		userLimits: l.userLimits,
+		overrides:  l.overrides,
+		observers:  l.observers,
+		schedule:   schedule,
+		mu:         l.mu,
+		logger:     l.logger,
+	}
 }
 
 func (l *SlidingWindowRateLimiter) IsLimited(userID string) bool {
+	return l.IsLimitedN(userID, 1, 0)
+}
+
+// IsLimitedN checks a request costing n units. A positive limit replaces the configured limit, but not the override of the client.
+func (l *SlidingWindowRateLimiter) IsLimitedN(userID string, n, limit int) bool {
 	l.logger.Debug("Checking if user exceeds rate limit")
 
 	l.mu.Lock()
-	defer func() {
-		l.incrementRequestCount(userID)
-		l.mu.Unlock()
-	}()
+	limited, events := l.isLimited(userID, n, limit, time.Now())
+	l.mu.Unlock()
+
+	// Notify the observers outside the lock, so they are able to call the limiter
+	l.notify(events)
+	return limited
+}
+
+func (l *SlidingWindowRateLimiter) isLimited(userID string, n, limit int, now time.Time) (bool, []Event) {
+	var events []Event
 
 	// Get the current user limits
 	userLimits, exists := l.userLimits[userID]
+
+	// Check if the window has expired
+	if exists && l.isExpired(userLimits, now) {
+		l.logger.Debug("Window expired, resetting request count")
+		events = l.appendEvent(events, EventWindowReset, userID, userLimits, now)
+		exists = false
+	}
+
+	// Open a new window on the first request
 	if !exists {
-		return false
+		userLimits = clientLimit{period: l.periodAt(now)}
+
+		windowStart := now
+		if config := l.windowConfig(userLimits); config.Aligned {
+			windowStart = now.Truncate(config.Duration)
+		}
+		userLimits.windowStart = &windowStart
+		events = l.appendEvent(events, EventWindowOpened, userID, userLimits, now)
 	}
 
-	// Check if the user has exceeded the limit
-	if userLimits.requestCount >= l.config.Limit {
+	// Check if the user has exceeded the limit and increment the request count
+	limited := userLimits.requestCount+n > l.limitFor(userID, userLimits, limit, now)
+	userLimits.requestCount += n
+	l.userLimits[userID] = userLimits
 
-		// Check if the window has expired
-		if userLimits.windowStart != nil && time.Since(*userLimits.windowStart) > l.config.Duration {
-			l.logger.Debug("Window expired, resetting request count")
-			// Reset the request count
-			currentTime := time.Now()
-			l.userLimits[userID] = clientLimit{
-				requestCount: 0,
-				windowStart:  &currentTime,
-			}
+	if limited {
+		events = l.appendEvent(events, EventRequestLimited, userID, userLimits, now)
+	} else {
+		events = l.appendEvent(events, EventRequestAllowed, userID, userLimits, now)
+	}
 
-			return false
+	return limited, events
+}
+
+// EvictExpired removes the clients with expired windows to free up memory. Returns the number of evicted clients.
+func (l *SlidingWindowRateLimiter) EvictExpired() int {
+	now := time.Now()
+	var events []Event
+
+	l.mu.Lock()
+	evicted := 0
+	for userID, userLimits := range l.userLimits {
+		if l.isExpired(userLimits, now) {
+			delete(l.userLimits, userID)
+			events = l.appendEvent(events, EventClientEvicted, userID, userLimits, now)
+			evicted++
 		}
+	}
+	l.mu.Unlock()
+
+	l.notify(events)
+	return evicted
+}
 
-		return true
+// isExpired checks whether the client's window has expired. Must be called with the lock held.
+func (l *SlidingWindowRateLimiter) isExpired(userLimits clientLimit, now time.Time) bool {
+	config := l.windowConfig(userLimits)
+	if config.Aligned {
+		return !now.Before(userLimits.windowStart.Add(config.Duration))
 	}
 
-	return false
+	return now.Sub(*userLimits.windowStart) > config.Duration
+}
+
+// appendEvent appends an event for the observers. Events are only created if there are any observers.
+func (l *SlidingWindowRateLimiter) appendEvent(events []Event, eventType EventType, userID string, userLimits clientLimit, now time.Time) []Event {
+	if len(l.observers) == 0 {
+		return events
+	}
+
+	return append(events, Event{
+		Type:        eventType,
+		ClientID:    userID,
+		Time:        now,
+		Count:       userLimits.requestCount,
+		Limit:       l.limitOf(userID, userLimits, now),
+		WindowStart: *userLimits.windowStart,
+	})
+}
+
+func (l *SlidingWindowRateLimiter) notify(events []Event) {
+	for _, event := range events {
+		for _, observer := range l.observers {
+			observer.OnEvent(event)
+		}
+	}
 }
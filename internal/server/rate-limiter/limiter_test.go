@@ -87,3 +87,120 @@ func TestNewSlidingWindowRateLimiterOpts(t *testing.T) {
 	assert.Equal(t, 200, rateLimiter.config.Limit)
 	assert.Equal(t, time.Second*5, rateLimiter.config.Duration)
 }
+
+func TestSlidingWindowRateLimiter_SetConfig(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	zap.ReplaceGlobals(logger)
+
+	rateLimiter := NewSlidingWindowRateLimiter(WithLimit(2))
+	assert.False(t, rateLimiter.IsLimited("1"))
+	assert.False(t, rateLimiter.IsLimited("1"))
+	assert.True(t, rateLimiter.IsLimited("1"))
+
+	// Raising the limit keeps the request count of the open window
+	rateLimiter.SetConfig(Config{Limit: 4, Duration: time.Second * 5})
+	assert.Equal(t, Config{Limit: 4, Duration: time.Second * 5}, rateLimiter.Config())
+	assert.False(t, rateLimiter.IsLimited("1"))
+	assert.True(t, rateLimiter.IsLimited("1"))
+}
+
+func TestSlidingWindowRateLimiter_Reconfigured(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	zap.ReplaceGlobals(logger)
+
+	rateLimiter := NewSlidingWindowRateLimiter(WithLimit(2))
+	assert.False(t, rateLimiter.IsLimited("1"))
+	assert.False(t, rateLimiter.IsLimited("1"))
+
+	// The reconfigured limiter keeps the request counts, while the previous limiter keeps its limits
+	reconfigured := rateLimiter.Reconfigured(Config{Limit: 4, Duration: time.Second * 5}, nil)
+	assert.Equal(t, 2, rateLimiter.Config().Limit)
+	assert.True(t, rateLimiter.IsLimited("1"))
+	assert.False(t, reconfigured.IsLimited("1"))
+	assert.True(t, reconfigured.IsLimited("1"))
+}
+
+func TestSlidingWindowRateLimiter_IsLimitedN(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	zap.ReplaceGlobals(logger)
+
+	rateLimiter := NewSlidingWindowRateLimiter(WithLimit(10))
+	assert.False(t, rateLimiter.IsLimitedN("1", 5, 0))
+	assert.False(t, rateLimiter.IsLimitedN("1", 5, 0))
+	assert.True(t, rateLimiter.IsLimitedN("1", 1, 0))
+
+	// The limit of the request replaces the configured limit
+	assert.False(t, rateLimiter.IsLimitedN("2", 5, 20))
+	assert.False(t, rateLimiter.IsLimitedN("2", 10, 20))
+	assert.True(t, rateLimiter.IsLimitedN("2", 10, 20))
+
+	// The override of the client takes precedence over the limit of the request
+	rateLimiter.SetOverride("3", 1, time.Minute)
+	assert.False(t, rateLimiter.IsLimitedN("3", 1, 20))
+	assert.True(t, rateLimiter.IsLimitedN("3", 1, 20))
+
+	// Limiters without weighted requests ignore the cost
+	var limiter Limiter = NewShadowLimiter("dry-run", rateLimiter, nil)
+	assert.False(t, IsLimitedN(limiter, "1", 100, 0))
+}
+
+func TestSlidingWindowRateLimiter_WindowReset(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	zap.ReplaceGlobals(logger)
+
+	rateLimiter := NewSlidingWindowRateLimiterFromConfig(Config{Limit: 2, Duration: 5 * time.Second})
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	limited, _ := rateLimiter.isLimited("1", 1, 0, now)
+	assert.False(t, limited)
+
+	// An expired window is reset by the next request, even if the client didn't reach the limit
+	now = now.Add(6 * time.Second)
+	limited, _ = rateLimiter.isLimited("1", 1, 0, now)
+	assert.False(t, limited)
+
+	state, _ := rateLimiter.Client("1")
+	assert.Equal(t, now, state.WindowStart)
+	assert.Equal(t, 1, state.Count)
+
+	limited, _ = rateLimiter.isLimited("1", 1, 0, now.Add(time.Second))
+	assert.False(t, limited)
+	limited, _ = rateLimiter.isLimited("1", 1, 0, now.Add(2*time.Second))
+	assert.True(t, limited)
+
+	// The window opened by the reset expires after its own duration
+	limited, _ = rateLimiter.isLimited("1", 1, 0, now.Add(5*time.Second+time.Millisecond))
+	assert.False(t, limited)
+}
+
+func TestSlidingWindowRateLimiter_Aligned(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	zap.ReplaceGlobals(logger)
+
+	rateLimiter := NewSlidingWindowRateLimiterFromConfig(Config{Limit: 2, Duration: time.Minute, Aligned: true})
+	now := time.Date(2024, 1, 1, 12, 0, 45, 0, time.UTC)
+
+	limited, _ := rateLimiter.isLimited("1", 1, 0, now)
+	assert.False(t, limited)
+	limited, _ = rateLimiter.isLimited("2", 1, 0, now.Add(10*time.Second))
+	assert.False(t, limited)
+
+	// The windows of both clients start at the beginning of the minute
+	for _, clientID := range []string{"1", "2"} {
+		state, _ := rateLimiter.Client(clientID)
+		assert.Equal(t, time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC), state.WindowStart)
+		assert.Equal(t, time.Date(2024, 1, 1, 12, 1, 0, 0, time.UTC), state.WindowEnd)
+	}
+
+	limited, _ = rateLimiter.isLimited("1", 1, 0, now.Add(10*time.Second))
+	assert.False(t, limited)
+	limited, _ = rateLimiter.isLimited("1", 1, 0, now.Add(14*time.Second))
+	assert.True(t, limited)
+
+	// The window resets at the start of the next minute, not a minute after the first request
+	limited, _ = rateLimiter.isLimited("1", 1, 0, now.Add(15*time.Second))
+	assert.False(t, limited)
+
+	state, _ := rateLimiter.Client("1")
+	assert.Equal(t, time.Date(2024, 1, 1, 12, 1, 0, 0, time.UTC), state.WindowStart)
+}
@@ -0,0 +1,58 @@
+package rate_limiter
+
+import (
+	"time"
+)
+
+type EventType string
+
+const (
+	// EventWindowOpened is emitted when a new window is opened for the client
+	EventWindowOpened EventType = "window_opened"
+
+	// EventRequestAllowed is emitted when the client's request is allowed
+	EventRequestAllowed EventType = "request_allowed"
+
+	// EventRequestLimited is emitted when the client's request is limited
+	EventRequestLimited EventType = "request_limited"
+
+	// EventWindowReset is emitted when the client's window expired and the request count is reset
+	EventWindowReset EventType = "window_reset"
+
+	// EventClientEvicted is emitted when the client's state is removed from the limiter
+	EventClientEvicted EventType = "client_evicted"
+
+	// EventClientBanned is emitted when the client is banned
+	EventClientBanned EventType = "client_banned"
+)
+
+// Event describes a decision or a state change of the limiter
+type Event struct {
+	Type     EventType
+	ClientID string
+	Time     time.Time
+
+	// Count is the number of requests made by the client in the window
+	Count int
+
+	// Limit is the maximum number of requests allowed within the window
+	Limit int
+
+	// WindowStart is the time when the window started
+	WindowStart time.Time
+
+	// Until is the time when the client's ban expires
+	Until time.Time
+}
+
+// Observer is notified about the limiter events. Observers are called synchronously, so they should not block.
+type Observer interface {
+	OnEvent(event Event)
+}
+
+// ObserverFunc is a function adapter for the Observer interface
+type ObserverFunc func(event Event)
+
+func (f ObserverFunc) OnEvent(event Event) {
+	f(event)
+}
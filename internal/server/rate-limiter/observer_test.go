@@ -0,0 +1,74 @@
+package rate_limiter
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type recordingObserver struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (o *recordingObserver) OnEvent(event Event) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.events = append(o.events, event)
+}
+
+func (o *recordingObserver) types() []EventType {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	types := []EventType{}
+	for _, event := range o.events {
+		types = append(types, event.Type)
+	}
+
+	return types
+}
+
+func TestObserver(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	zap.ReplaceGlobals(logger)
+
+	observer := &recordingObserver{}
+	rateLimiter := NewSlidingWindowRateLimiterFromConfig(Config{Limit: 1, Duration: 100 * time.Millisecond}, WithObserver(observer))
+
+	assert.False(t, rateLimiter.IsLimited("1"))
+	assert.True(t, rateLimiter.IsLimited("1"))
+	assert.Equal(t, []EventType{EventWindowOpened, EventRequestAllowed, EventRequestLimited}, observer.types())
+	assert.Equal(t, "1", observer.events[2].ClientID)
+	assert.Equal(t, 2, observer.events[2].Count)
+	assert.Equal(t, 1, observer.events[2].Limit)
+
+	// The window expires
+	time.Sleep(150 * time.Millisecond)
+	assert.False(t, rateLimiter.IsLimited("1"))
+	assert.Equal(t, []EventType{EventWindowReset, EventWindowOpened, EventRequestAllowed}, observer.types()[3:])
+
+	// Expired clients are evicted
+	time.Sleep(150 * time.Millisecond)
+	assert.Equal(t, 1, rateLimiter.EvictExpired())
+	assert.Equal(t, EventClientEvicted, observer.types()[6])
+	assert.Equal(t, 0, rateLimiter.EvictExpired())
+}
+
+func TestObserver_CallsLimiter(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	zap.ReplaceGlobals(logger)
+
+	// Observers can call the limiter without deadlocking
+	var rateLimiter *SlidingWindowRateLimiter
+	observer := ObserverFunc(func(event Event) {
+		_ = rateLimiter.Config()
+	})
+	rateLimiter = NewSlidingWindowRateLimiter(WithObserver(observer))
+
+	assert.False(t, rateLimiter.IsLimited("1"))
+}
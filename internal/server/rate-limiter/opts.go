@@ -27,3 +27,14 @@ func WithDuration(duration time.Duration) Options {
 		l.config.Duration = duration
 	}
 }
+
+// WithObserver registers an observer, which is notified about the limiter events.
+func WithObserver(observer Observer) Options {
+	return func(l *SlidingWindowRateLimiter) {
+		if observer == nil {
+			return
+		}
+
+		l.observers = append(l.observers, observer)
+	}
+}
@@ -0,0 +1,56 @@
+package rate_limiter
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Schedule replaces the limits of the limiter during its periods (e.g. business hours).
+type Schedule interface {
+	// At returns the name of the period active at the time
+	At(t time.Time) (string, bool)
+
+	// Period returns the limits of the period
+	Period(name string) (Config, bool)
+}
+
+// WithSchedule sets the schedule of the limits. Windows opened outside the periods of the schedule use the configured limits.
+func WithSchedule(schedule Schedule) Options {
+	return func(l *SlidingWindowRateLimiter) {
+		l.schedule = schedule
+	}
+}
+
+// SetSchedule replaces the schedule. The open windows keep their periods, unless the periods were removed from the schedule.
+func (l *SlidingWindowRateLimiter) SetSchedule(schedule Schedule) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.logger.Info("Updating the rate limiter schedule", zap.Bool("scheduled", schedule != nil))
+	l.schedule = schedule
+}
+
+// periodAt returns the period of the schedule active at the time, or an empty string. Must be called with the lock held.
+func (l *SlidingWindowRateLimiter) periodAt(now time.Time) string {
+	if l.schedule == nil {
+		return ""
+	}
+
+	period, _ := l.schedule.At(now)
+	return period
+}
+
+// windowConfig returns the limits of the period the window was opened in, so the transitions between the periods
+// don't change the limits of the open windows. Must be called with the lock held.
+func (l *SlidingWindowRateLimiter) windowConfig(userLimits clientLimit) Config {
+	if userLimits.period == "" || l.schedule == nil {
+		return l.config
+	}
+
+	if config, exists := l.schedule.Period(userLimits.period); exists {
+		return config
+	}
+
+	return l.config
+}
@@ -0,0 +1,57 @@
+package rate_limiter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// staticSchedule has a single period, active when the flag is set
+type staticSchedule struct {
+	active bool
+	config Config
+}
+
+func (s *staticSchedule) At(time.Time) (string, bool) {
+	if s.active {
+		return "peak", true
+	}
+
+	return "", false
+}
+
+func (s *staticSchedule) Period(name string) (Config, bool) {
+	return s.config, name == "peak"
+}
+
+func TestSlidingWindowRateLimiter_Schedule(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	zap.ReplaceGlobals(logger)
+
+	schedule := &staticSchedule{active: true, config: Config{Limit: 1, Duration: time.Second * 5}}
+	rateLimiter := NewSlidingWindowRateLimiter(WithLimit(3), WithSchedule(schedule))
+
+	// The window is opened during the peak period
+	assert.False(t, rateLimiter.IsLimited("1"))
+	assert.True(t, rateLimiter.IsLimited("1"))
+
+	// The open window keeps the limit of the peak period after the transition
+	schedule.active = false
+	assert.True(t, rateLimiter.IsLimited("1"))
+
+	state, _ := rateLimiter.Client("1")
+	assert.Equal(t, 1, state.Limit)
+
+	// New windows use the configured limit
+	for i := 0; i < 3; i++ {
+		assert.False(t, rateLimiter.IsLimited("2"))
+	}
+	assert.True(t, rateLimiter.IsLimited("2"))
+
+	// Removing the schedule reverts the open windows to the configured limit
+	rateLimiter.SetSchedule(nil)
+	state, _ = rateLimiter.Client("1")
+	assert.Equal(t, 3, state.Limit)
+}
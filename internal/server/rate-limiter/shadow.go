@@ -0,0 +1,115 @@
+package rate_limiter
+
+import (
+	"sync/atomic"
+
+	"go.uber.org/zap"
+)
+
+// ShadowStats are the decision counters of a shadow limiter
+type ShadowStats struct {
+	Name string `json:"name"`
+
+	// Evaluated is the number of evaluated requests
+	Evaluated uint64 `json:"evaluated"`
+
+	// ShadowLimited is the number of requests that would have been limited by the shadow limiter
+	ShadowLimited uint64 `json:"shadowLimited"`
+
+	// EnforcedLimited is the number of requests limited by the enforcing limiter
+	EnforcedLimited uint64 `json:"enforcedLimited"`
+
+	// Disagreements is the number of requests the shadow and the enforcing limiter decided differently on
+	Disagreements uint64 `json:"disagreements"`
+}
+
+// ShadowLimiter evaluates the shadow limiter in dry-run mode: its decisions are only logged and counted, but never enforced.
+// If an enforcing limiter is set, its decisions are enforced and compared with the shadow limiter's decisions.
+type ShadowLimiter struct {
+	name      string
+	shadow    Limiter
+	enforcing Limiter
+
+	evaluated       atomic.Uint64
+	shadowLimited   atomic.Uint64
+	enforcedLimited atomic.Uint64
+	disagreements   atomic.Uint64
+
+	logger *zap.Logger
+}
+
+// NewShadowLimiter creates a new shadow limiter. The enforcing limiter is optional.
+func NewShadowLimiter(name string, shadow Limiter, enforcing Limiter) *ShadowLimiter {
+	return &ShadowLimiter{
+		name:      name,
+		shadow:    shadow,
+		enforcing: enforcing,
+		logger:    zap.L().Named("shadow-limiter").With(zap.String("name", name)),
+	}
+}
+
+func (s *ShadowLimiter) IsLimited(clientID string) bool {
+	return s.IsLimitedN(clientID, 1, 0)
+}
+
+// IsLimitedN checks a request costing n units with both limiters.
+func (s *ShadowLimiter) IsLimitedN(clientID string, n, limit int) bool {
+	s.evaluated.Add(1)
+
+	wouldLimit := IsLimitedN(s.shadow, clientID, n, limit)
+	if wouldLimit {
+		s.shadowLimited.Add(1)
+	}
+
+	// Dry-run only
+	if s.enforcing == nil {
+		if wouldLimit {
+			s.logger.Info("Request would have been limited", zap.String("clientId", clientID))
+		}
+
+		return false
+	}
+
+	isLimited := IsLimitedN(s.enforcing, clientID, n, limit)
+	if isLimited {
+		s.enforcedLimited.Add(1)
+	}
+
+	if isLimited != wouldLimit {
+		s.disagreements.Add(1)
+		s.logger.Info("Shadow limiter disagrees with the enforcing limiter",
+			zap.String("clientId", clientID),
+			zap.Bool("limited", isLimited),
+			zap.Bool("shadowLimited", wouldLimit),
+		)
+	}
+
+	return isLimited
+}
+
+// Stats returns the decision counters of the shadow limiter
+func (s *ShadowLimiter) Stats() ShadowStats {
+	return ShadowStats{
+		Name:            s.name,
+		Evaluated:       s.evaluated.Load(),
+		ShadowLimited:   s.shadowLimited.Load(),
+		EnforcedLimited: s.enforcedLimited.Load(),
+		Disagreements:   s.disagreements.Load(),
+	}
+}
+
+// Client returns the client's state in the enforcing limiter or, in dry-run mode, in the shadow limiter
+func (s *ShadowLimiter) Client(clientID string) (ClientState, bool) {
+	limiter := s.enforcing
+	if limiter == nil {
+		limiter = s.shadow
+	}
+
+	if inspector, ok := limiter.(interface {
+		Client(clientID string) (ClientState, bool)
+	}); ok {
+		return inspector.Client(clientID)
+	}
+
+	return ClientState{}, false
+}
@@ -0,0 +1,48 @@
+package rate_limiter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestShadowLimiter_DryRun(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	zap.ReplaceGlobals(logger)
+
+	shadow := NewShadowLimiter("dry-run", NewSlidingWindowRateLimiter(WithLimit(2)), nil)
+
+	// The shadow limiter never limits the requests
+	for i := 0; i < 5; i++ {
+		assert.False(t, shadow.IsLimited("1"))
+	}
+
+	stats := shadow.Stats()
+	assert.Equal(t, "dry-run", stats.Name)
+	assert.EqualValues(t, 5, stats.Evaluated)
+	assert.EqualValues(t, 3, stats.ShadowLimited)
+	assert.EqualValues(t, 0, stats.EnforcedLimited)
+}
+
+func TestShadowLimiter_Candidate(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	zap.ReplaceGlobals(logger)
+
+	enforcing := NewSlidingWindowRateLimiter(WithLimit(3))
+	candidate := NewSlidingWindowRateLimiter(WithLimit(1))
+	shadow := NewShadowLimiter("candidate", candidate, enforcing)
+
+	// The enforcing limiter's decisions are enforced
+	limited := []bool{}
+	for i := 0; i < 4; i++ {
+		limited = append(limited, shadow.IsLimited("1"))
+	}
+	assert.Equal(t, []bool{false, false, false, true}, limited)
+
+	stats := shadow.Stats()
+	assert.EqualValues(t, 4, stats.Evaluated)
+	assert.EqualValues(t, 3, stats.ShadowLimited)
+	assert.EqualValues(t, 1, stats.EnforcedLimited)
+	assert.EqualValues(t, 2, stats.Disagreements)
+}
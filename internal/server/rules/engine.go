@@ -0,0 +1,185 @@
+package rules
+
+import (
+	"net/http"
+	"net/netip"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/access"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/expressions"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/rate-limiter"
+)
+
+// DefaultTier is the tier of the clients not matching any of the tiers
+const DefaultTier = "default"
+
+// Rule maps the matching requests to a limiter with its own counters.
+// Empty matchers match all requests.
+type Rule struct {
+	Name string
+
+	// Methods are the HTTP methods (e.g. POST)
+	Methods []string
+
+	// Path is a glob (e.g. /users/*/orders). A trailing /** matches the path and all the paths below it.
+	Path string
+
+	// Headers must be present with the exact value. An empty value or * matches any value.
+	Headers map[string]string
+
+	// Tiers of the client
+	Tiers []string
+
+	// Sources are the IP ranges of the client
+	Sources []netip.Prefix
+
+	// Limiter counts the requests matching the rule
+	Limiter rate_limiter.Limiter
+
+	// Expressions compute the client ID, the cost and the limit of the requests. Optional.
+	Expressions *expressions.Expressions
+
+	// Priority is the priority class of the requests (e.g. critical). Optional.
+	Priority string
+}
+
+// Tier groups the clients by their client IDs, client ID prefixes and IP ranges.
+type Tier struct {
+	Name    string
+	Clients *access.List
+}
+
+// Request is the part of the request the rules match on
+type Request struct {
+	Method   string
+	Path     string
+	Header   http.Header
+	ClientID string
+	IP       netip.Addr
+}
+
+// Engine evaluates the rules in order. The first matching rule applies.
+type Engine struct {
+	rules []Rule
+	tiers []Tier
+}
+
+// NewEngine creates an engine with ordered rules and tiers. The client is in the first tier it matches.
+func NewEngine(rules []Rule, tiers []Tier) (*Engine, error) {
+	for _, rule := range rules {
+		if rule.Limiter == nil {
+			return nil, errors.Errorf("rule %s has no limiter", rule.Name)
+		}
+
+		if _, err := path.Match(strings.TrimSuffix(rule.Path, "/**"), ""); err != nil {
+			return nil, errors.Wrapf(err, "invalid path of the rule %s", rule.Name)
+		}
+	}
+
+	return &Engine{rules: rules, tiers: tiers}, nil
+}
+
+// Rules returns the rules in the order of evaluation.
+func (e *Engine) Rules() []Rule {
+	return e.rules
+}
+
+// Tier returns the first tier of the client, or the DefaultTier.
+func (e *Engine) Tier(clientID string, ip netip.Addr) string {
+	for _, tier := range e.tiers {
+		if tier.Clients.Matches(clientID, ip) {
+			return tier.Name
+		}
+	}
+
+	return DefaultTier
+}
+
+// Match returns the first rule matching the request and the tier of the client.
+func (e *Engine) Match(req Request) (Rule, string, bool) {
+	tier := e.Tier(req.ClientID, req.IP)
+
+	for _, rule := range e.rules {
+		if rule.matches(req, tier) {
+			return rule, tier, true
+		}
+	}
+
+	return Rule{}, tier, false
+}
+
+func (r Rule) matches(req Request, tier string) bool {
+	if len(r.Methods) > 0 && !containsFold(r.Methods, req.Method) {
+		return false
+	}
+
+	if r.Path != "" && !matchPath(r.Path, req.Path) {
+		return false
+	}
+
+	for name, value := range r.Headers {
+		values := req.Header.Values(name)
+		if len(values) == 0 || (value != "" && value != "*" && !contains(values, value)) {
+			return false
+		}
+	}
+
+	if len(r.Tiers) > 0 && !contains(r.Tiers, tier) {
+		return false
+	}
+
+	if len(r.Sources) > 0 {
+		matched := false
+		for _, source := range r.Sources {
+			if req.IP.IsValid() && source.Contains(req.IP.Unmap()) {
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchPath matches the path with the glob. The trailing /** matches the path and all the paths below it.
+func matchPath(pattern, value string) bool {
+	if prefix, isRecursive := strings.CutSuffix(pattern, "/**"); isRecursive {
+		// Match the prefix with the same number of leading segments
+		prefixSegments := strings.Split(prefix, "/")
+		segments := strings.Split(value, "/")
+		if len(segments) < len(prefixSegments) {
+			return false
+		}
+
+		pattern, value = prefix, strings.Join(segments[:len(prefixSegments)], "/")
+	}
+
+	matched, _ := path.Match(pattern, value)
+	return matched
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+
+	return false
+}
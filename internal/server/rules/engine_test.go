@@ -0,0 +1,111 @@
+package rules
+
+import (
+	"net/http"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/access"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/rate-limiter"
+)
+
+func TestMatchPath(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		matches bool
+	}{
+		{pattern: "/login", path: "/login", matches: true},
+		{pattern: "/login", path: "/login/2fa", matches: false},
+		{pattern: "/users/*/orders", path: "/users/42/orders", matches: true},
+		{pattern: "/users/*/orders", path: "/users/42/items", matches: false},
+		{pattern: "/static/**", path: "/static", matches: true},
+		{pattern: "/static/**", path: "/static/css/main.css", matches: true},
+		{pattern: "/static/**", path: "/staticfiles", matches: false},
+		{pattern: "/**", path: "/anything/at/all", matches: true},
+		{pattern: "/api/*/search/**", path: "/api/v1/search/users", matches: true},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.matches, matchPath(test.pattern, test.path), "%s %s", test.pattern, test.path)
+	}
+}
+
+func TestEngine_Match(t *testing.T) {
+	limiter := rate_limiter.NewSlidingWindowRateLimiter()
+	premium, _ := access.NewList("premium-*")
+	internal, _ := access.NewList("10.0.0.0/8")
+
+	engine, err := NewEngine([]Rule{
+		{Name: "internal", Sources: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}, Limiter: limiter},
+		{Name: "login", Methods: []string{http.MethodPost}, Path: "/login", Limiter: limiter},
+		{Name: "search-premium", Path: "/search", Tiers: []string{"premium"}, Limiter: limiter},
+		{Name: "search", Path: "/search", Limiter: limiter},
+		{Name: "beta", Headers: map[string]string{"X-Beta": "*"}, Limiter: limiter},
+	}, []Tier{
+		{Name: "premium", Clients: premium},
+		{Name: "internal", Clients: internal},
+	})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name string
+		req  Request
+		rule string
+		tier string
+	}{
+		{
+			name: "source",
+			req:  Request{Method: http.MethodGet, Path: "/search", ClientID: "1", IP: netip.MustParseAddr("10.1.2.3")},
+			rule: "internal",
+			tier: "internal",
+		},
+		{
+			name: "method and path",
+			req:  Request{Method: http.MethodPost, Path: "/login", ClientID: "1"},
+			rule: "login",
+			tier: DefaultTier,
+		},
+		{
+			name: "method mismatch",
+			req:  Request{Method: http.MethodGet, Path: "/login", ClientID: "1"},
+			tier: DefaultTier,
+		},
+		{
+			name: "tier",
+			req:  Request{Method: http.MethodGet, Path: "/search", ClientID: "premium-1"},
+			rule: "search-premium",
+			tier: "premium",
+		},
+		{
+			name: "order",
+			req:  Request{Method: http.MethodGet, Path: "/search", ClientID: "1"},
+			rule: "search",
+			tier: DefaultTier,
+		},
+		{
+			name: "header",
+			req:  Request{Method: http.MethodGet, Path: "/", Header: http.Header{"X-Beta": {"yes"}}, ClientID: "1"},
+			rule: "beta",
+			tier: DefaultTier,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rule, tier, matched := engine.Match(test.req)
+			assert.Equal(t, test.rule != "", matched)
+			assert.Equal(t, test.rule, rule.Name)
+			assert.Equal(t, test.tier, tier)
+		})
+	}
+}
+
+func TestNewEngine_InvalidRule(t *testing.T) {
+	_, err := NewEngine([]Rule{{Name: "no-limiter"}}, nil)
+	assert.Error(t, err)
+
+	_, err = NewEngine([]Rule{{Name: "bad-path", Path: "/[", Limiter: rate_limiter.NewSlidingWindowRateLimiter()}}, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,166 @@
+package schedule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/robfig/cron/v3"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/rate-limiter"
+)
+
+// Period is a recurring period with its own limits
+type Period struct {
+	Name   string
+	Config rate_limiter.Config
+
+	// isActive checks whether the period is active at the time
+	isActive func(t time.Time) bool
+}
+
+// NewTimeRange creates a period active daily between from and to (e.g. 09:00-17:00) on the days of the week.
+// The range can cross midnight (e.g. 22:00-06:00), in which case the day is the day the range starts. All days match if empty.
+func NewTimeRange(name string, config rate_limiter.Config, days []time.Weekday, from, to TimeOfDay, location *time.Location) Period {
+	matchesDay := func(day time.Weekday) bool {
+		if len(days) == 0 {
+			return true
+		}
+
+		for _, d := range days {
+			if d == day {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	return Period{
+		Name:   name,
+		Config: config,
+		isActive: func(t time.Time) bool {
+			t = t.In(location)
+			now := timeOfDay(t)
+
+			if from <= to {
+				return now >= from && now < to && matchesDay(t.Weekday())
+			}
+
+			// The range crosses midnight
+			if now >= from {
+				return matchesDay(t.Weekday())
+			}
+
+			return now < to && matchesDay(t.AddDate(0, 0, -1).Weekday())
+		},
+	}
+}
+
+// NewCron creates a period starting with every activation of the cron expression (e.g. 0 2 * * SUN) and lasting for the duration.
+func NewCron(name string, config rate_limiter.Config, expression string, duration time.Duration, location *time.Location) (Period, error) {
+	schedule, err := cron.ParseStandard(expression)
+	if err != nil {
+		return Period{}, errors.Wrapf(err, "invalid cron expression %q", expression)
+	}
+
+	if duration <= 0 {
+		return Period{}, errors.New("the duration must be positive")
+	}
+
+	return Period{
+		Name:   name,
+		Config: config,
+		isActive: func(t time.Time) bool {
+			// The period is active if it started within the duration before the time
+			t = t.In(location)
+			start := schedule.Next(t.Add(-duration))
+			return !start.After(t)
+		},
+	}, nil
+}
+
+// Schedule selects the first active period. It implements rate_limiter.Schedule.
+type Schedule struct {
+	periods []Period
+}
+
+// New creates a schedule with the periods in the order of precedence.
+func New(periods ...Period) (*Schedule, error) {
+	names := map[string]bool{}
+	for _, period := range periods {
+		if period.Name == "" || names[period.Name] {
+			return nil, errors.Errorf("the period names must be unique and not empty: %q", period.Name)
+		}
+
+		names[period.Name] = true
+	}
+
+	return &Schedule{periods: periods}, nil
+}
+
+// At returns the name of the first period active at the time.
+func (s *Schedule) At(t time.Time) (string, bool) {
+	for _, period := range s.periods {
+		if period.isActive(t) {
+			return period.Name, true
+		}
+	}
+
+	return "", false
+}
+
+// Period returns the limits of the period.
+func (s *Schedule) Period(name string) (rate_limiter.Config, bool) {
+	for _, period := range s.periods {
+		if period.Name == name {
+			return period.Config, true
+		}
+	}
+
+	return rate_limiter.Config{}, false
+}
+
+// TimeOfDay is the time since midnight
+type TimeOfDay time.Duration
+
+// ParseTimeOfDay parses the time of day in the HH:MM format. 24:00 is the end of the day.
+func ParseTimeOfDay(value string) (TimeOfDay, error) {
+	var hours, minutes int
+	if _, err := fmt.Sscanf(value, "%d:%d", &hours, &minutes); err != nil || len(value) != 5 {
+		return 0, errors.Errorf("invalid time of day %q, expected HH:MM", value)
+	}
+
+	if hours < 0 || minutes < 0 || minutes > 59 || hours > 24 || (hours == 24 && minutes > 0) {
+		return 0, errors.Errorf("invalid time of day %q", value)
+	}
+
+	return TimeOfDay(time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute), nil
+}
+
+func timeOfDay(t time.Time) TimeOfDay {
+	hour, minute, second := t.Clock()
+	return TimeOfDay(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute + time.Duration(second)*time.Second + time.Duration(t.Nanosecond()))
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// ParseWeekday parses the day of the week (e.g. mon or Monday).
+func ParseWeekday(value string) (time.Weekday, error) {
+	value = strings.ToLower(value)
+	if len(value) >= 3 {
+		if day, exists := weekdays[value[:3]]; exists && strings.HasPrefix(strings.ToLower(day.String()), value) {
+			return day, nil
+		}
+	}
+
+	return 0, errors.Errorf("invalid day of the week %q", value)
+}
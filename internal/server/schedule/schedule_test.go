@@ -0,0 +1,100 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/rate-limiter"
+)
+
+func mustTimeOfDay(t *testing.T, value string) TimeOfDay {
+	t.Helper()
+
+	timeOfDay, err := ParseTimeOfDay(value)
+	assert.NoError(t, err)
+	return timeOfDay
+}
+
+func TestSchedule_At(t *testing.T) {
+	location, err := time.LoadLocation("Europe/Ljubljana")
+	assert.NoError(t, err)
+
+	businessHours := NewTimeRange(
+		"business-hours",
+		rate_limiter.Config{Limit: 100, Duration: time.Second},
+		[]time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+		mustTimeOfDay(t, "09:00"), mustTimeOfDay(t, "17:00"),
+		location,
+	)
+	night := NewTimeRange("night", rate_limiter.Config{Limit: 1000, Duration: time.Second}, nil, mustTimeOfDay(t, "22:00"), mustTimeOfDay(t, "06:00"), location)
+	maintenance, err := NewCron("maintenance", rate_limiter.Config{Limit: 10, Duration: time.Second}, "0 2 * * SUN", 2*time.Hour, location)
+	assert.NoError(t, err)
+
+	schedule, err := New(maintenance, businessHours, night)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		time   time.Time
+		period string
+	}{
+		// Monday, 2024-01-08
+		{time: time.Date(2024, 1, 8, 9, 0, 0, 0, location), period: "business-hours"},
+		{time: time.Date(2024, 1, 8, 16, 59, 0, 0, location), period: "business-hours"},
+		{time: time.Date(2024, 1, 8, 17, 0, 0, 0, location), period: ""},
+		// The time zone of the period is respected
+		{time: time.Date(2024, 1, 8, 8, 30, 0, 0, time.UTC), period: "business-hours"},
+		// Saturday
+		{time: time.Date(2024, 1, 13, 12, 0, 0, 0, location), period: ""},
+		// Crossing midnight
+		{time: time.Date(2024, 1, 13, 23, 0, 0, 0, location), period: "night"},
+		{time: time.Date(2024, 1, 14, 1, 0, 0, 0, location), period: "night"},
+		// The maintenance on Sunday takes precedence over the night
+		{time: time.Date(2024, 1, 14, 2, 0, 0, 0, location), period: "maintenance"},
+		{time: time.Date(2024, 1, 14, 3, 59, 0, 0, location), period: "maintenance"},
+		{time: time.Date(2024, 1, 14, 4, 0, 0, 0, location), period: "night"},
+	}
+
+	for _, test := range tests {
+		period, isActive := schedule.At(test.time)
+		assert.Equal(t, test.period != "", isActive, test.time)
+		assert.Equal(t, test.period, period, test.time)
+	}
+
+	config, exists := schedule.Period("night")
+	assert.True(t, exists)
+	assert.Equal(t, 1000, config.Limit)
+}
+
+func TestParse(t *testing.T) {
+	timeOfDay, err := ParseTimeOfDay("09:30")
+	assert.NoError(t, err)
+	assert.Equal(t, TimeOfDay(9*time.Hour+30*time.Minute), timeOfDay)
+
+	_, err = ParseTimeOfDay("9:30")
+	assert.Error(t, err)
+
+	_, err = ParseTimeOfDay("24:30")
+	assert.Error(t, err)
+
+	day, err := ParseWeekday("Monday")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Monday, day)
+
+	day, err = ParseWeekday("sat")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Saturday, day)
+
+	_, err = ParseWeekday("mo")
+	assert.Error(t, err)
+
+	_, err = NewCron("invalid", rate_limiter.Config{}, "0 2 * *", time.Hour, time.UTC)
+	assert.Error(t, err)
+
+	_, err = New(businessHoursPeriod(), businessHoursPeriod())
+	assert.Error(t, err)
+}
+
+func businessHoursPeriod() Period {
+	return NewTimeRange("business-hours", rate_limiter.Config{}, nil, 9*TimeOfDay(time.Hour), 17*TimeOfDay(time.Hour), time.UTC)
+}
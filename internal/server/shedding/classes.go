@@ -0,0 +1,80 @@
+package shedding
+
+import (
+	"net/http"
+	"net/netip"
+
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/access"
+)
+
+var priorityNames = map[Priority]string{
+	PriorityLow:      "low",
+	PriorityNormal:   "normal",
+	PriorityHigh:     "high",
+	PriorityCritical: "critical",
+}
+
+// ParsePriority parses the name of a priority class (low, normal, high or critical).
+func ParsePriority(name string) (Priority, bool) {
+	for priority, priorityName := range priorityNames {
+		if priorityName == name {
+			return priority, true
+		}
+	}
+
+	return PriorityNormal, false
+}
+
+func (p Priority) String() string {
+	if name, exists := priorityNames[p]; exists {
+		return name
+	}
+
+	return "unknown"
+}
+
+// Request is the part of the request the priority is derived from
+type Request struct {
+	Header   http.Header
+	ClientID string
+	IP       netip.Addr
+	Tier     string
+
+	// RulePriority is the priority class of the matched rule, if set
+	RulePriority string
+}
+
+// Classes derive the priority of the requests. The priority header of the trusted clients takes precedence
+// over the priority of the matched rule, which takes precedence over the priority of the client's tier.
+type Classes struct {
+	// Default is the priority of the requests without a priority
+	Default Priority
+
+	// Header carries the priority class (e.g. critical) of the requests from the trusted clients. Optional.
+	Header string
+
+	// Trusted are the clients allowed to set their priority with the header
+	Trusted *access.List
+
+	// Tiers are the priorities of the clients in the tiers
+	Tiers map[string]Priority
+}
+
+// Priority derives the priority of the request.
+func (c *Classes) Priority(req Request) Priority {
+	if c.Header != "" && c.Trusted != nil && c.Trusted.Matches(req.ClientID, req.IP) {
+		if priority, ok := ParsePriority(req.Header.Get(c.Header)); ok {
+			return priority
+		}
+	}
+
+	if priority, ok := ParsePriority(req.RulePriority); ok {
+		return priority
+	}
+
+	if priority, exists := c.Tiers[req.Tier]; exists {
+		return priority
+	}
+
+	return c.Default
+}
@@ -0,0 +1,72 @@
+package shedding
+
+import (
+	"net/http"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/access"
+)
+
+func TestParsePriority(t *testing.T) {
+	priority, ok := ParsePriority("critical")
+	assert.True(t, ok)
+	assert.Equal(t, PriorityCritical, priority)
+	assert.Equal(t, "critical", priority.String())
+
+	_, ok = ParsePriority("urgent")
+	assert.False(t, ok)
+}
+
+func TestClasses_Priority(t *testing.T) {
+	trusted, _ := access.NewList("internal-*", "10.0.0.0/8")
+	classes := &Classes{
+		Default: PriorityNormal,
+		Header:  "X-Priority",
+		Trusted: trusted,
+		Tiers:   map[string]Priority{"free": PriorityLow, "premium": PriorityHigh},
+	}
+
+	header := http.Header{}
+	header.Set("X-Priority", "critical")
+
+	tests := []struct {
+		name     string
+		request  Request
+		expected Priority
+	}{
+		{name: "default", request: Request{ClientID: "1", Tier: "default"}, expected: PriorityNormal},
+		{name: "tier", request: Request{ClientID: "1", Tier: "free"}, expected: PriorityLow},
+		{name: "rule", request: Request{ClientID: "1", Tier: "free", RulePriority: "high"}, expected: PriorityHigh},
+		{name: "trusted client", request: Request{Header: header, ClientID: "internal-billing", Tier: "free"}, expected: PriorityCritical},
+		{name: "trusted IP", request: Request{Header: header, ClientID: "1", IP: netip.MustParseAddr("10.0.0.1")}, expected: PriorityCritical},
+		{name: "untrusted client", request: Request{Header: header, ClientID: "1", Tier: "premium"}, expected: PriorityHigh},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, classes.Priority(test.request))
+		})
+	}
+}
+
+func TestLimiter_Reserved(t *testing.T) {
+	limiter := NewLimiter(WithLimits(10, 100), WithInitialLimit(10), WithReserved(0.2, 0.2, 0.2))
+
+	// The reserved capacity of the critical requests is kept while the lower priorities are shed
+	for i := 0; i < 8; i++ {
+		_, ok := limiter.Acquire(PriorityHigh)
+		assert.True(t, ok)
+	}
+
+	_, ok := limiter.Acquire(PriorityHigh)
+	assert.False(t, ok)
+	_, ok = limiter.Acquire(PriorityLow)
+	assert.False(t, ok)
+
+	for i := 0; i < 2; i++ {
+		_, ok = limiter.Acquire(PriorityCritical)
+		assert.True(t, ok)
+	}
+}
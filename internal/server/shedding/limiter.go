@@ -0,0 +1,154 @@
+// Package shedding adapts a server-wide concurrency limit to the observed latency and saturation of the server,
+// and sheds the requests over the limit, starting with the lowest priorities.
+package shedding
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Priority of a request. Lower priorities are shed first when the server is saturated.
+type Priority int
+
+const (
+	PriorityLow Priority = iota
+	PriorityNormal
+	PriorityHigh
+	PriorityCritical
+)
+
+// Config of the adaptive limiter
+type Config struct {
+	// MinLimit and MaxLimit bound the concurrency limit
+	MinLimit int
+	MaxLimit int
+
+	// InitialLimit is the concurrency limit before any latency is observed
+	InitialLimit int
+
+	// TargetLatency is the handler latency above which the server is considered saturated
+	TargetLatency time.Duration
+
+	// Backoff is the factor the limit is multiplied with when the server is saturated
+	Backoff float64
+}
+
+// Limiter is an AIMD concurrency limiter. The limit grows by one with every request completed below the target latency
+// while at least half of the limit is in use, and is multiplied by the backoff at most once per round trip when
+// a request exceeds the target latency or any of the signals reports saturation.
+type Limiter struct {
+	config Config
+
+	// shares of the limit the priorities can use, so the lower priorities are shed before the higher priorities
+	shares [PriorityCritical + 1]float64
+
+	// signals report the saturation of the server besides the latency (e.g. the Go runtime)
+	signals []Signal
+
+	limit       float64
+	inFlight    int
+	decreasedAt time.Time
+
+	mu  sync.Mutex
+	now func() time.Time
+}
+
+// NewLimiter creates an adaptive limiter with the provided options
+func NewLimiter(opts ...Options) *Limiter {
+	l := &Limiter{
+		config: Config{
+			MinLimit:      10,
+			MaxLimit:      1000,
+			InitialLimit:  100,
+			TargetLatency: 50 * time.Millisecond,
+			Backoff:       0.9,
+		},
+		shares: [...]float64{
+			PriorityLow:      0.5,
+			PriorityNormal:   0.8,
+			PriorityHigh:     0.95,
+			PriorityCritical: 1,
+		},
+		now: time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	l.limit = math.Min(math.Max(float64(l.config.InitialLimit), float64(l.config.MinLimit)), float64(l.config.MaxLimit))
+	return l
+}
+
+// Acquire admits the request if the requests in flight are within the priority's share of the limit.
+// The release function must be called when the request completes, as the latency of the request adapts the limit.
+// Calling it more than once has no effect.
+func (l *Limiter) Acquire(priority Priority) (release func(), ok bool) {
+	priority = min(max(priority, PriorityLow), PriorityCritical)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if float64(l.inFlight) >= l.limit*l.shares[priority] {
+		return func() {}, false
+	}
+
+	l.inFlight++
+	start := l.now()
+
+	once := sync.Once{}
+	return func() {
+		once.Do(func() { l.release(l.now().Sub(start)) })
+	}, true
+}
+
+// release adapts the limit to the latency of the completed request.
+func (l *Limiter) release(latency time.Duration) {
+	saturated := latency > l.config.TargetLatency || l.saturated()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	switch {
+	case saturated:
+		// Back off once per round trip, as the requests in flight observed the same saturation
+		if now.Sub(l.decreasedAt) >= max(latency, l.config.TargetLatency) {
+			l.limit = math.Max(l.limit*l.config.Backoff, float64(l.config.MinLimit))
+			l.decreasedAt = now
+		}
+	case float64(l.inFlight)*2 >= l.limit:
+		// Only grow the limit if it is being used
+		l.limit = math.Min(l.limit+1, float64(l.config.MaxLimit))
+	}
+
+	l.inFlight--
+}
+
+// saturated reports whether any of the signals reports saturation.
+func (l *Limiter) saturated() bool {
+	for _, signal := range l.signals {
+		if signal() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Limit returns the current concurrency limit.
+func (l *Limiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return int(l.limit)
+}
+
+// InFlight returns the number of requests in flight.
+func (l *Limiter) InFlight() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.inFlight
+}
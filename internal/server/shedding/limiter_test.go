@@ -0,0 +1,110 @@
+package shedding
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiter_Priorities(t *testing.T) {
+	limiter := NewLimiter(WithLimits(10, 100), WithInitialLimit(10))
+
+	// Lower priorities can only use part of the limit
+	admitted := map[Priority]int{}
+	for _, priority := range []Priority{PriorityLow, PriorityNormal, PriorityHigh, PriorityCritical} {
+		for {
+			if _, ok := limiter.Acquire(priority); !ok {
+				break
+			}
+			admitted[priority]++
+		}
+	}
+
+	assert.Equal(t, map[Priority]int{PriorityLow: 5, PriorityNormal: 3, PriorityHigh: 2}, admitted)
+	assert.Equal(t, 10, limiter.InFlight())
+}
+
+func TestLimiter_AIMD(t *testing.T) {
+	now := time.Now()
+	limiter := NewLimiter(WithLimits(10, 12), WithInitialLimit(10), WithTargetLatency(100*time.Millisecond), WithBackoff(0.5))
+	limiter.now = func() time.Time { return now }
+
+	// The limit grows while it is being used and the requests are fast
+	releases := []func(){}
+	for i := 0; i < 8; i++ {
+		release, ok := limiter.Acquire(PriorityCritical)
+		assert.True(t, ok)
+		releases = append(releases, release)
+	}
+
+	now = now.Add(10 * time.Millisecond)
+	for _, release := range releases {
+		release()
+	}
+	assert.Equal(t, 0, limiter.InFlight())
+
+	// Grown up to the maximum while at least half of the limit was in flight
+	assert.Equal(t, 12, limiter.Limit())
+
+	// Releasing more than once has no effect
+	releases[0]()
+	assert.Equal(t, 0, limiter.InFlight())
+
+	// Slow requests back off the limit once per round trip
+	release1, _ := limiter.Acquire(PriorityNormal)
+	release2, _ := limiter.Acquire(PriorityNormal)
+	now = now.Add(200 * time.Millisecond)
+	release1()
+	release2()
+	assert.Equal(t, 10, limiter.Limit())
+
+	limiter.limit = 40
+	release, _ := limiter.Acquire(PriorityNormal)
+	now = now.Add(200 * time.Millisecond)
+	release()
+	assert.Equal(t, 20, limiter.Limit())
+}
+
+func TestLimiter_Signal(t *testing.T) {
+	now := time.Now()
+	saturated := true
+	limiter := NewLimiter(WithLimits(10, 100), WithInitialLimit(50), WithSignal(func() bool { return saturated }))
+	limiter.now = func() time.Time { return now }
+
+	// Fast requests back off the limit if the signals report saturation
+	release, _ := limiter.Acquire(PriorityNormal)
+	release()
+	assert.Equal(t, 45, limiter.Limit())
+
+	saturated = false
+	release, _ = limiter.Acquire(PriorityNormal)
+	release()
+	assert.Equal(t, 45, limiter.Limit())
+}
+
+func TestQueueDepth(t *testing.T) {
+	depth := 5
+	signal := QueueDepth(func() int { return depth }, 10)
+	assert.False(t, signal())
+
+	depth = 11
+	assert.True(t, signal())
+}
+
+func TestPercentile(t *testing.T) {
+	buckets := []float64{0, 0.001, 0.01, 0.1, math.Inf(1)}
+
+	assert.Equal(t, 0.0, percentile(buckets, []uint64{0, 0, 0, 0}, 0.99))
+	assert.Equal(t, 0.001, percentile(buckets, []uint64{100, 0, 0, 0}, 0.99))
+	assert.Equal(t, 0.1, percentile(buckets, []uint64{90, 0, 10, 0}, 0.99))
+	assert.Equal(t, 0.1, percentile(buckets, []uint64{90, 0, 0, 10}, 0.99))
+}
+
+func TestSchedulingLatency(t *testing.T) {
+	// Idle tests are never saturated for a second
+	signal := SchedulingLatency(time.Second, time.Minute)
+	assert.False(t, signal())
+	assert.False(t, signal())
+}
@@ -0,0 +1,81 @@
+package shedding
+
+import (
+	"time"
+)
+
+type Options func(*Limiter)
+
+// WithLimits bounds the concurrency limit.
+func WithLimits(minLimit, maxLimit int) Options {
+	return func(l *Limiter) {
+		// The minimum must be positive and the maximum can't be lower than the minimum
+		if minLimit < 1 || maxLimit < minLimit {
+			return
+		}
+
+		l.config.MinLimit = minLimit
+		l.config.MaxLimit = maxLimit
+	}
+}
+
+// WithInitialLimit sets the concurrency limit before any latency is observed. The limit is kept within the bounds.
+func WithInitialLimit(limit int) Options {
+	return func(l *Limiter) {
+		if limit < 1 {
+			return
+		}
+
+		l.config.InitialLimit = limit
+	}
+}
+
+// WithTargetLatency sets the latency above which the server is considered saturated.
+func WithTargetLatency(latency time.Duration) Options {
+	return func(l *Limiter) {
+		if latency <= 0 {
+			return
+		}
+
+		l.config.TargetLatency = latency
+	}
+}
+
+// WithBackoff sets the factor the limit is multiplied with when the server is saturated.
+func WithBackoff(backoff float64) Options {
+	return func(l *Limiter) {
+		// The backoff must decrease the limit
+		if backoff <= 0 || backoff >= 1 {
+			return
+		}
+
+		l.config.Backoff = backoff
+	}
+}
+
+// WithSignal adds a signal reporting the saturation of the server.
+func WithSignal(signal Signal) Options {
+	return func(l *Limiter) {
+		if signal == nil {
+			return
+		}
+
+		l.signals = append(l.signals, signal)
+	}
+}
+
+// WithReserved reserves shares of the limit for the requests of the higher priorities, which the lower priorities can't use.
+// The low priority requests can use the rest of the limit.
+func WithReserved(critical, high, normal float64) Options {
+	return func(l *Limiter) {
+		// The low priority requests must be able to use part of the limit
+		if critical < 0 || high < 0 || normal < 0 || critical+high+normal >= 1 {
+			return
+		}
+
+		l.shares[PriorityCritical] = 1
+		l.shares[PriorityHigh] = 1 - critical
+		l.shares[PriorityNormal] = 1 - critical - high
+		l.shares[PriorityLow] = 1 - critical - high - normal
+	}
+}
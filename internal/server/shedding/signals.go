@@ -0,0 +1,98 @@
+package shedding
+
+import (
+	"math"
+	"runtime"
+	"runtime/metrics"
+	"sync"
+	"time"
+)
+
+// Signal reports whether the server is saturated, regardless of the latency of the requests.
+// Signals are checked whenever a request completes, so they must be cheap.
+type Signal func() bool
+
+// Goroutines reports saturation when the number of goroutines exceeds the maximum.
+func Goroutines(maxGoroutines int) Signal {
+	return func() bool {
+		return runtime.NumGoroutine() > maxGoroutines
+	}
+}
+
+// QueueDepth reports saturation when the depth of a queue (e.g. the requests waiting for admission) exceeds the maximum.
+func QueueDepth(depth func() int, maxDepth int) Signal {
+	return func() bool {
+		return depth() > maxDepth
+	}
+}
+
+// schedulingLatencyMetric is the distribution of the time goroutines spend runnable before running
+const schedulingLatencyMetric = "/sched/latencies:seconds"
+
+// SchedulingLatency reports saturation when the 99th percentile of the time goroutines wait for a CPU exceeds the maximum.
+// The percentile is computed from the goroutines scheduled since the previous sample, sampled at most once per interval.
+func SchedulingLatency(maxLatency, interval time.Duration) Signal {
+	mu := sync.Mutex{}
+	sample := []metrics.Sample{{Name: schedulingLatencyMetric}}
+	previous := []uint64{}
+	sampledAt := time.Time{}
+	saturated := false
+
+	return func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if time.Since(sampledAt) < interval {
+			return saturated
+		}
+		sampledAt = time.Now()
+
+		metrics.Read(sample)
+		if sample[0].Value.Kind() != metrics.KindFloat64Histogram {
+			return false
+		}
+
+		histogram := sample[0].Value.Float64Histogram()
+		delta := make([]uint64, len(histogram.Counts))
+		for i, count := range histogram.Counts {
+			delta[i] = count
+			if i < len(previous) {
+				delta[i] -= previous[i]
+			}
+		}
+		previous = append(previous[:0], histogram.Counts...)
+
+		saturated = percentile(histogram.Buckets, delta, 0.99) > maxLatency.Seconds()
+		return saturated
+	}
+}
+
+// percentile returns the upper bound of the bucket containing the percentile of the histogram.
+func percentile(buckets []float64, counts []uint64, p float64) float64 {
+	total := uint64(0)
+	for _, count := range counts {
+		total += count
+	}
+
+	if total == 0 {
+		return 0
+	}
+
+	threshold := uint64(math.Ceil(float64(total) * p))
+	cumulative := uint64(0)
+	for i, count := range counts {
+		cumulative += count
+		if cumulative < threshold {
+			continue
+		}
+
+		// The last bucket is unbounded
+		if math.IsInf(buckets[i+1], 1) {
+			return buckets[i]
+		}
+
+		return buckets[i+1]
+	}
+
+	return buckets[len(buckets)-1]
+}
@@ -0,0 +1,39 @@
+package tarpit
+
+import (
+	"time"
+)
+
+type Options func(*Tarpit)
+
+func WithDelay(base, max time.Duration) Options {
+	return func(t *Tarpit) {
+		// Delays must be positive and the maximum can't be shorter than the base delay
+		if base <= 0 || max < base {
+			return
+		}
+
+		t.config.BaseDelay = base
+		t.config.MaxDelay = max
+	}
+}
+
+func WithMaxConnections(connections int) Options {
+	return func(t *Tarpit) {
+		if connections < 1 {
+			return
+		}
+
+		t.config.MaxConnections = connections
+	}
+}
+
+func WithForgetAfter(duration time.Duration) Options {
+	return func(t *Tarpit) {
+		if duration <= 0 {
+			return
+		}
+
+		t.config.ForgetAfter = duration
+	}
+}
@@ -0,0 +1,189 @@
+// Package tarpit slows down abusive clients by holding their responses for a delay, which grows with every offense.
+package tarpit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Config of the tarpit
+type Config struct {
+	// BaseDelay is the delay after the first offense. Every further offense doubles the delay.
+	BaseDelay time.Duration
+
+	// MaxDelay is the upper bound for the delay
+	MaxDelay time.Duration
+
+	// MaxConnections is the maximum number of connections held at once. Responses are sent without a delay above it.
+	MaxConnections int
+
+	// ForgetAfter is the duration after the last offense, after which the client's offenses are forgotten
+	ForgetAfter time.Duration
+}
+
+type offender struct {
+	offenses int
+	lastAt   time.Time
+}
+
+// Tarpit keeps track of the client offenses (e.g. limited or banned requests) and holds the connections of the offenders.
+type Tarpit struct {
+	config Config
+
+	// offenders is a map of client IDs to their offenses
+	offenders map[string]*offender
+
+	// held is the number of connections held
+	held int
+
+	// released is closed when the tarpit stops, which releases the held connections
+	released chan struct{}
+
+	mu  sync.Mutex
+	now func() time.Time
+}
+
+// New creates a tarpit with the provided options
+func New(opts ...Options) *Tarpit {
+	t := &Tarpit{
+		config: Config{
+			BaseDelay:      500 * time.Millisecond,
+			MaxDelay:       30 * time.Second,
+			MaxConnections: 100,
+			ForgetAfter:    5 * time.Minute,
+		},
+		offenders: make(map[string]*offender),
+		released:  make(chan struct{}),
+		now:       time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	return t
+}
+
+// Offend records an offense of the client and returns the delay of the client's response.
+func (t *Tarpit) Offend(clientID string) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	o, exists := t.offenders[clientID]
+	if !exists || t.isForgotten(o, now) {
+		o = &offender{}
+		t.offenders[clientID] = o
+	}
+
+	o.offenses++
+	o.lastAt = now
+	return t.delay(o.offenses)
+}
+
+// Delay returns the delay of the responses to the client's requests, which are not offenses themselves.
+// Clients without recent offenses are not delayed.
+func (t *Tarpit) Delay(clientID string) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	o, exists := t.offenders[clientID]
+	if !exists || t.isForgotten(o, t.now()) {
+		return 0
+	}
+
+	return t.delay(o.offenses)
+}
+
+// delay calculates the delay after the n-th offense
+func (t *Tarpit) delay(offenses int) time.Duration {
+	delay := t.config.BaseDelay
+	for i := 1; i < offenses; i++ {
+		delay *= 2
+		if delay >= t.config.MaxDelay {
+			return t.config.MaxDelay
+		}
+	}
+
+	return min(delay, t.config.MaxDelay)
+}
+
+func (t *Tarpit) isForgotten(o *offender, now time.Time) bool {
+	return now.Sub(o.lastAt) > t.config.ForgetAfter
+}
+
+// Hold holds the connection for the delay, until the context is done or the tarpit stops. Returns false without waiting
+// if the maximum number of connections are already held.
+func (t *Tarpit) Hold(ctx context.Context, delay time.Duration) bool {
+	if delay <= 0 {
+		return false
+	}
+
+	t.mu.Lock()
+	if t.held >= t.config.MaxConnections {
+		t.mu.Unlock()
+		return false
+	}
+	t.held++
+	t.mu.Unlock()
+
+	defer func() {
+		t.mu.Lock()
+		t.held--
+		t.mu.Unlock()
+	}()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	case <-t.released:
+	}
+
+	return true
+}
+
+// Held returns the number of connections held.
+func (t *Tarpit) Held() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.held
+}
+
+// EvictExpired forgets the clients without recent offenses. Returns the number of forgotten clients.
+func (t *Tarpit) EvictExpired() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	evicted := 0
+	for clientID, o := range t.offenders {
+		if t.isForgotten(o, now) {
+			delete(t.offenders, clientID)
+			evicted++
+		}
+	}
+
+	return evicted
+}
+
+// Run periodically forgets the clients without recent offenses, until the context is done. The held connections are
+// released when the context is done, as the server shutdown doesn't cancel the contexts of the requests.
+func (t *Tarpit) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			close(t.released)
+			return
+		case <-ticker.C:
+			t.EvictExpired()
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package tarpit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTarpit_Delay(t *testing.T) {
+	now := time.Now()
+	tarpit := New(WithDelay(time.Second, 5*time.Second), WithForgetAfter(time.Minute))
+	tarpit.now = func() time.Time { return now }
+
+	assert.Equal(t, time.Duration(0), tarpit.Delay("1"))
+
+	// The delay doubles with every offense, up to the maximum
+	expectedDelays := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for _, expectedDelay := range expectedDelays {
+		assert.Equal(t, expectedDelay, tarpit.Offend("1"))
+	}
+
+	// The other requests of the offender are delayed as well
+	assert.Equal(t, 5*time.Second, tarpit.Delay("1"))
+	assert.Equal(t, time.Duration(0), tarpit.Delay("2"))
+
+	// The offenses are forgotten after the client behaved for long enough
+	now = now.Add(time.Minute + time.Second)
+	assert.Equal(t, time.Duration(0), tarpit.Delay("1"))
+	assert.Equal(t, 1, tarpit.EvictExpired())
+	assert.Equal(t, time.Second, tarpit.Offend("1"))
+}
+
+func TestTarpit_Hold(t *testing.T) {
+	tarpit := New(WithMaxConnections(1))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	held := make(chan bool)
+	go func() {
+		held <- tarpit.Hold(ctx, time.Minute)
+	}()
+	assert.Eventually(t, func() bool { return tarpit.Held() == 1 }, time.Second, time.Millisecond)
+
+	// The number of held connections is bounded
+	assert.False(t, tarpit.Hold(context.Background(), time.Minute))
+
+	// The connection is released when the client disconnects
+	cancel()
+	assert.True(t, <-held)
+	assert.Equal(t, 0, tarpit.Held())
+
+	assert.True(t, tarpit.Hold(context.Background(), time.Millisecond))
+	assert.False(t, tarpit.Hold(context.Background(), 0))
+}
+
+func TestTarpit_Release(t *testing.T) {
+	tarpit := New()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		tarpit.Run(ctx, time.Minute)
+	}()
+
+	held := make(chan bool)
+	go func() {
+		held <- tarpit.Hold(context.Background(), time.Minute)
+	}()
+	assert.Eventually(t, func() bool { return tarpit.Held() == 1 }, time.Second, time.Millisecond)
+
+	// The held connections are released when the tarpit stops, e.g. on shutdown
+	cancel()
+	<-stopped
+	assert.True(t, <-held)
+	assert.Equal(t, 0, tarpit.Held())
+}
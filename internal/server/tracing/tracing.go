@@ -0,0 +1,103 @@
+package tracing
+
+import (
+	"context"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+)
+
+// Config of the tracing
+type Config struct {
+	// Exporter of the spans. Supported exporters: none, otlp, stdout
+	Exporter string
+
+	// Endpoint of the OTLP collector (e.g. otel-collector:4317). If empty, the OTEL_EXPORTER_OTLP_ENDPOINT environment variable is used.
+	Endpoint string
+
+	// Insecure disables TLS for the OTLP exporter
+	Insecure bool
+
+	// SampleRatio is the ratio of traces sampled, if the parent span is not sampled already
+	SampleRatio float64
+
+	// ServiceName is the name of the service in the traces
+	ServiceName string
+
+	// writer is the output of the stdout exporter
+	writer io.Writer
+}
+
+// ShutdownFunc flushes the remaining spans and stops the exporter
+type ShutdownFunc func(ctx context.Context) error
+
+// Setup sets up the global tracer provider and the propagation of the trace context from the incoming headers.
+func Setup(ctx context.Context, cfg Config) (ShutdownFunc, error) {
+	// Propagate the trace context even if the spans are not exported
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var (
+		exporter sdktrace.SpanExporter
+		err      error
+	)
+
+	switch cfg.Exporter {
+	case ExporterNone, "":
+		return func(ctx context.Context) error { return nil }, nil
+	case ExporterStdout:
+		writer := cfg.writer
+		if writer == nil {
+			writer = os.Stdout
+		}
+
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(writer))
+	case ExporterOTLP:
+		opts := []otlptracegrpc.Option{}
+		if cfg.Endpoint != "" {
+			opts = append(opts, otlptracegrpc.WithEndpoint(cfg.Endpoint))
+		}
+
+		if cfg.Insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+
+		exporter, err = otlptracegrpc.New(ctx, opts...)
+	default:
+		return nil, errors.Errorf("unsupported exporter: %s", cfg.Exporter)
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create the exporter")
+	}
+
+	serviceResource, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(cfg.ServiceName),
+	))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create the resource")
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(serviceResource),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
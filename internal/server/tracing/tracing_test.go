@@ -0,0 +1,34 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+)
+
+func TestSetup(t *testing.T) {
+	ctx := context.Background()
+
+	// No exporter
+	shutdown, err := Setup(ctx, Config{Exporter: ExporterNone})
+	assert.NoError(t, err)
+	assert.NoError(t, shutdown(ctx))
+
+	// Unsupported exporter
+	_, err = Setup(ctx, Config{Exporter: "zipkin"})
+	assert.Error(t, err)
+
+	// Spans are exported to the writer
+	output := &bytes.Buffer{}
+	shutdown, err = Setup(ctx, Config{Exporter: ExporterStdout, SampleRatio: 1, ServiceName: "test", writer: output})
+	assert.NoError(t, err)
+
+	_, span := otel.Tracer("test").Start(ctx, "test-span")
+	span.End()
+
+	assert.NoError(t, shutdown(ctx))
+	assert.Contains(t, output.String(), "test-span")
+}
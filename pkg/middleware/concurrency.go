@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/rate-limiter"
+)
+
+// ConcurrencyLimiter limits the number of requests a client can have in flight at once
+type ConcurrencyLimiter interface {
+	// Acquire acquires a slot for the client's request. The release function frees the slot and is safe to call more than once.
+	Acquire(clientID string) (release func(), ok bool)
+}
+
+// NewConcurrencyLimiter creates an in-memory limiter, which allows the client the limit of requests in flight at once.
+// A limit of zero or less means the clients have no limit.
+func NewConcurrencyLimiter(limit int) ConcurrencyLimiter {
+	return rate_limiter.NewConcurrencyLimiter(limit)
+}
+
+// GinConcurrency creates a gin middleware, which holds the client's slot until the rest of the handlers complete.
+// The slot is released even if a handler panics or the client disconnects before the handler completes.
+func GinConcurrency(limiter ConcurrencyLimiter, opts ...Option) gin.HandlerFunc {
+	o := newOptions(opts...)
+
+	return func(ctx *gin.Context) {
+		clientID, isFound := o.ginKey(ctx)
+		release, err := acquire(limiter, clientID, isFound)
+		if err != nil {
+			o.errorHandler(ctx.Writer, ctx.Request, err)
+			ctx.Abort()
+			return
+		}
+		defer release()
+
+		ctx.Next()
+	}
+}
+
+// HandlerConcurrency creates a net/http middleware, which holds the client's slot until the next handler completes.
+// The slot is released even if the handler panics or the client disconnects before the handler completes.
+func HandlerConcurrency(limiter ConcurrencyLimiter, opts ...Option) func(next http.Handler) http.Handler {
+	o := newOptions(opts...)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			clientID, isFound := o.keyFunc(r)
+			release, err := acquire(limiter, clientID, isFound)
+			if err != nil {
+				o.errorHandler(w, r, err)
+				return
+			}
+			defer release()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// acquire acquires a slot for the request, or returns the reason for rejecting the request.
+func acquire(limiter ConcurrencyLimiter, clientID string, isFound bool) (func(), error) {
+	if !isFound || clientID == "" {
+		return nil, ErrMissingKey
+	}
+
+	release, ok := limiter.Acquire(clientID)
+	if !ok {
+		return nil, ErrTooManyInFlight
+	}
+
+	return release, nil
+}
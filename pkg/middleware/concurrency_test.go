@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	rate_limiter "github.com/xBlaz3kx/rate-limiter-example/internal/server/rate-limiter"
+)
+
+func TestGinConcurrency(t *testing.T) {
+	limiter := rate_limiter.NewConcurrencyLimiter(1)
+	entered := make(chan struct{})
+	done := make(chan struct{})
+
+	r := gin.New()
+	r.Use(GinConcurrency(limiter))
+	r.GET("/export", func(ctx *gin.Context) {
+		if ctx.Query("block") != "" {
+			close(entered)
+			<-done
+		}
+		ctx.Status(http.StatusOK)
+	})
+
+	finished := make(chan int)
+	go func() {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/export?clientId=1&block=1", nil))
+		finished <- w.Code
+	}()
+	<-entered
+
+	// The client already has a request in flight
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/export?clientId=1", nil))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.JSONEq(t, `{"error":"too many requests in flight"}`, w.Body.String())
+
+	// Other clients are not affected
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/export?clientId=2", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// The slot is released when the request completes
+	close(done)
+	assert.Equal(t, http.StatusOK, <-finished)
+	assert.Equal(t, 0, limiter.InFlight("1"))
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/export?clientId=1", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestGinConcurrency_Panic(t *testing.T) {
+	limiter := rate_limiter.NewConcurrencyLimiter(1)
+
+	r := gin.New()
+	r.Use(gin.Recovery(), GinConcurrency(limiter))
+	r.GET("/export", func(ctx *gin.Context) {
+		panic("export failed")
+	})
+
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/export?clientId=1", nil))
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	}
+	assert.Equal(t, 0, limiter.InFlight("1"))
+}
+
+func TestHandlerConcurrency_Disconnect(t *testing.T) {
+	limiter := rate_limiter.NewConcurrencyLimiter(1)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Stop working once the client is gone
+		<-r.Context().Done()
+	})
+	handler := HandlerConcurrency(limiter, WithKeyFunc(HeaderKey("X-Client-ID")))(next)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+	req.Header.Set("X-Client-ID", "1")
+
+	finished := make(chan struct{})
+	go func() {
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		close(finished)
+	}()
+
+	cancel()
+	<-finished
+	assert.Equal(t, 0, limiter.InFlight("1"))
+
+	// The client ID is missing
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
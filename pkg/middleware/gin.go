@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// Gin creates a gin middleware, which calls the next handler if the client is allowed and aborts the request otherwise.
+func Gin(limiter Limiter, opts ...Option) gin.HandlerFunc {
+	o := newOptions(opts...)
+
+	return func(ctx *gin.Context) {
+		clientID, isFound := o.ginKey(ctx)
+		if err := check(limiter, clientID, isFound); err != nil {
+			o.errorHandler(ctx.Writer, ctx.Request, err)
+			ctx.Abort()
+			return
+		}
+
+		ctx.Next()
+	}
+}
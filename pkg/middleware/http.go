@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"net/http"
+)
+
+// Handler creates a net/http middleware, which calls the next handler if the client is allowed.
+func Handler(limiter Limiter, opts ...Option) func(next http.Handler) http.Handler {
+	o := newOptions(opts...)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			clientID, isFound := o.keyFunc(r)
+			if err := check(limiter, clientID, isFound); err != nil {
+				o.errorHandler(w, r, err)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
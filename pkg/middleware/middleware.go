@@ -0,0 +1,151 @@
+// Package middleware protects gin routes and net/http handlers with a rate limiter.
+package middleware
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/xBlaz3kx/rate-limiter-example/internal/server/rate-limiter"
+)
+
+var (
+	// ErrLimited is passed to the error handler when the client exceeded its rate limit
+	ErrLimited = errors.New("rate limit exceeded")
+
+	// ErrMissingKey is passed to the error handler when the client ID is missing from the request
+	ErrMissingKey = errors.New("missing client ID")
+
+	// ErrTooManyInFlight is passed to the error handler when the client has too many requests in flight
+	ErrTooManyInFlight = errors.New("too many requests in flight")
+)
+
+// Limiter decides whether a client has exceeded its rate limit
+type Limiter interface {
+	IsLimited(clientID string) bool
+}
+
+// NewLimiter creates an in-memory limiter, which allows the client the limit of requests within the duration of the window.
+func NewLimiter(limit int, duration time.Duration) Limiter {
+	return rate_limiter.NewSlidingWindowRateLimiterFromConfig(rate_limiter.Config{Limit: limit, Duration: duration})
+}
+
+// KeyFunc extracts the client ID from the request
+type KeyFunc func(r *http.Request) (string, bool)
+
+// GinKeyFunc extracts the client ID from the gin context. Used by the gin middleware instead of the KeyFunc, if set.
+type GinKeyFunc func(ctx *gin.Context) (string, bool)
+
+// ErrorHandler writes the response to rejected requests. The error is ErrLimited, ErrTooManyInFlight or ErrMissingKey.
+type ErrorHandler func(w http.ResponseWriter, r *http.Request, err error)
+
+// QueryKey extracts the client ID from a query parameter.
+func QueryKey(name string) KeyFunc {
+	return func(r *http.Request) (string, bool) {
+		value := r.URL.Query().Get(name)
+		return value, value != ""
+	}
+}
+
+// HeaderKey extracts the client ID from a header (e.g. X-Client-ID).
+func HeaderKey(name string) KeyFunc {
+	return func(r *http.Request) (string, bool) {
+		value := r.Header.Get(name)
+		return value, value != ""
+	}
+}
+
+// RemoteAddrKey uses the IP address of the connection as the client ID. Proxy headers are not trusted.
+func RemoteAddrKey() KeyFunc {
+	return func(r *http.Request) (string, bool) {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			return r.RemoteAddr, r.RemoteAddr != ""
+		}
+
+		return host, host != ""
+	}
+}
+
+type Option func(*options)
+
+type options struct {
+	keyFunc      KeyFunc
+	ginKeyFunc   GinKeyFunc
+	errorHandler ErrorHandler
+}
+
+// WithKeyFunc sets the client ID extraction. By default, the client ID is read from the clientId query parameter.
+func WithKeyFunc(keyFunc KeyFunc) Option {
+	return func(o *options) {
+		o.keyFunc = keyFunc
+	}
+}
+
+// WithGinKeyFunc sets the client ID extraction of the gin middleware, e.g. to key the clients by the matched route.
+func WithGinKeyFunc(keyFunc GinKeyFunc) Option {
+	return func(o *options) {
+		o.ginKeyFunc = keyFunc
+	}
+}
+
+// WithErrorHandler replaces the default responses to the rejected requests.
+func WithErrorHandler(errorHandler ErrorHandler) Option {
+	return func(o *options) {
+		o.errorHandler = errorHandler
+	}
+}
+
+// ginKey extracts the client ID with the gin key function, if set, and with the key function otherwise.
+func (o *options) ginKey(ctx *gin.Context) (string, bool) {
+	if o.ginKeyFunc != nil {
+		return o.ginKeyFunc(ctx)
+	}
+
+	return o.keyFunc(ctx.Request)
+}
+
+func newOptions(opts ...Option) *options {
+	o := &options{
+		keyFunc:      QueryKey("clientId"),
+		errorHandler: DefaultErrorHandler,
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return o
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// DefaultErrorHandler responds with 429 Too Many Requests to limited clients and with 400 Bad Request if the client ID is missing.
+func DefaultErrorHandler(w http.ResponseWriter, _ *http.Request, err error) {
+	status := http.StatusTooManyRequests
+	if errors.Is(err, ErrMissingKey) {
+		status = http.StatusBadRequest
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(errorResponse{Error: err.Error()})
+}
+
+// check returns the reason for rejecting the request, or nil if the request is allowed.
+func check(limiter Limiter, clientID string, isFound bool) error {
+	if !isFound || clientID == "" {
+		return ErrMissingKey
+	}
+
+	if limiter.IsLimited(clientID) {
+		return ErrLimited
+	}
+
+	return nil
+}
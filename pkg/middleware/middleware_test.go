@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	rate_limiter "github.com/xBlaz3kx/rate-limiter-example/internal/server/rate-limiter"
+)
+
+func TestGin(t *testing.T) {
+	calls := 0
+	r := gin.New()
+	r.Use(Gin(rate_limiter.NewSlidingWindowRateLimiter(rate_limiter.WithLimit(2))))
+	r.GET("/orders", func(ctx *gin.Context) {
+		calls++
+		ctx.Status(http.StatusOK)
+	})
+
+	expectedCodes := []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests}
+	for _, expectedCode := range expectedCodes {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/orders?clientId=1", nil))
+		assert.Equal(t, expectedCode, w.Code)
+	}
+	assert.Equal(t, 2, calls)
+
+	// The client ID is missing
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/orders", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"error":"missing client ID"}`, w.Body.String())
+	assert.Equal(t, 2, calls)
+}
+
+func TestGin_KeyFunc(t *testing.T) {
+	r := gin.New()
+	r.Use(Gin(
+		rate_limiter.NewSlidingWindowRateLimiter(rate_limiter.WithLimit(1)),
+		WithGinKeyFunc(func(ctx *gin.Context) (string, bool) {
+			return ctx.GetHeader("X-Client-ID") + ctx.FullPath(), ctx.GetHeader("X-Client-ID") != ""
+		}),
+	))
+	r.GET("/orders", func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+	r.GET("/users", func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+
+	// Every route has its own limit
+	for _, path := range []string{"/orders", "/users"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("X-Client-ID", "1")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	var rejected error
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	})
+	handler := Handler(
+		NewLimiter(1, time.Minute),
+		WithKeyFunc(HeaderKey("X-Client-ID")),
+		WithErrorHandler(func(w http.ResponseWriter, r *http.Request, err error) {
+			rejected = err
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}),
+	)(next)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Client-ID", "1")
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.NoError(t, rejected)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.True(t, errors.Is(rejected, ErrLimited))
+}
+
+func TestRemoteAddrKey(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("X-Forwarded-For", "192.168.0.1")
+
+	key, isFound := RemoteAddrKey()(req)
+	assert.True(t, isFound)
+	assert.Equal(t, "10.0.0.1", key)
+}